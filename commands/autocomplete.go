@@ -0,0 +1,52 @@
+package commands
+
+import (
+	"github.com/ayunami2000/ayunsdcord/sdapi"
+)
+
+// Value sources for slash command autocompletion
+
+func modelNames() ([]string, error) {
+	res, err := sdapi.GetModels()
+	if err != nil {
+		return nil, err
+	}
+
+	return res.Options.StableDiffusion, nil
+}
+
+func vaeNames() ([]string, error) {
+	res, err := sdapi.GetModels()
+	if err != nil {
+		return nil, err
+	}
+
+	return res.Options.VAE, nil
+}
+
+func hyperNetworkNames() ([]string, error) {
+	res, err := sdapi.GetModels()
+	if err != nil {
+		return nil, err
+	}
+
+	return res.Options.HyperNetwork, nil
+}
+
+func loraNames() ([]string, error) {
+	res, err := sdapi.GetModels()
+	if err != nil {
+		return nil, err
+	}
+
+	return res.Options.Lora, nil
+}
+
+func controlNetNames() ([]string, error) {
+	res, err := sdapi.GetModels()
+	if err != nil {
+		return nil, err
+	}
+
+	return res.Options.ControlNet, nil
+}
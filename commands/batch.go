@@ -0,0 +1,40 @@
+package commands
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/ayunami2000/ayunsdcord/commands/command"
+	"github.com/ayunami2000/ayunsdcord/config"
+)
+
+// The grid and the images have to fit in one message, which can hold 10 attachments
+const maxBatchSize = 9
+
+var BatchCommand = command.NewCommand("batch", []string{"ba"}, batchRun).Describe("Shows or sets the number of images per render",
+	command.Option{Name: "size", Description: "Number of images, from 1 to 9", Type: command.IntegerOption})
+var ErrInvalidBatchSize = errors.New("invalid batch size")
+
+func batchRun(cmdctx *command.CommandContext) error {
+	if cmdctx.Args == "" {
+		_, err := cmdctx.TryReply("**Current batch size:** %d", cmdctx.ChannelSettings.BatchSize)
+		return err
+	}
+
+	if err := config.CanChange("batch"); err != nil {
+		return err
+	}
+
+	i, err := strconv.ParseUint(cmdctx.Args, 10, 64)
+	if err != nil {
+		return err
+	}
+
+	if i < 1 || i > maxBatchSize {
+		return ErrInvalidBatchSize
+	}
+
+	cmdctx.ChannelSettings.BatchSize = uint(i)
+	_, err = cmdctx.TryReply("**Batch size set to:** %d", cmdctx.ChannelSettings.BatchSize)
+	return err
+}
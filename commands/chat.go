@@ -12,7 +12,8 @@ import (
 	"github.com/diamondburned/arikawa/v3/discord"
 )
 
-var ChatCommand = command.NewCommand("chat", []string{"ch"}, chatRun)
+var ChatCommand = command.NewCommand("chat", []string{"ch"}, chatRun).Describe("Chats with the language model",
+	command.Option{Name: "message", Description: "What to say", Type: command.StringOption})
 var ErrChatDisabled = errors.New("chat is disabled")
 var ChatLock = sync.Mutex{}
 
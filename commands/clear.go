@@ -3,27 +3,48 @@ package commands
 import (
 	"errors"
 	"fmt"
+	"sort"
 	"strings"
 
 	"github.com/ayunami2000/ayunsdcord/commands/command"
 	"github.com/ayunami2000/ayunsdcord/config"
 )
 
-var ClearCommand = command.NewCommand("clear", []string{"cl"}, clearCommandRun)
+var ClearCommand = command.NewCommand("clear", []string{"cl"}, clearCommandRun).Describe("Resets a setting to its default",
+	command.Option{Name: "property", Description: "Setting to reset", Type: command.StringOption, Choices: clearableProperties()})
 var ErrCannotChangeProperty = errors.New("not allowed to change property")
 var ErrInvalidProperty = errors.New("invalid property specified")
 
 var chgMap = map[string]string{
-	"p":  "prompt",
-	"np": "negativeprompt",
-	"ps": "promptstrength",
-	"is": "inferencesteps",
-	"gs": "guidancescale",
-	"sz": "size",
-	"v":  "vae",
-	"hn": "hypernetwork",
-	"u":  "upscaler",
-	"ua": "upscaleamount",
+	"p":   "prompt",
+	"np":  "negativeprompt",
+	"ps":  "promptstrength",
+	"is":  "inferencesteps",
+	"gs":  "guidancescale",
+	"sz":  "size",
+	"v":   "vae",
+	"hn":  "hypernetwork",
+	"u":   "upscaler",
+	"ua":  "upscaleamount",
+	"se":  "seed",
+	"ba":  "batch",
+	"cn":  "controlnet",
+	"lo":  "lora",
+	"dp":  "dynamicprompts",
+	"ff":  "facefix",
+	"ti":  "tiling",
+	"fmt": "format",
+	"q":   "quality",
+}
+
+func clearableProperties() []string {
+	properties := []string{}
+	for _, v := range chgMap {
+		properties = append(properties, v)
+	}
+
+	sort.Strings(properties)
+	return properties
 }
 
 func clearCommandRun(cmdctx *command.CommandContext) error {
@@ -43,7 +64,7 @@ func clearCommandRun(cmdctx *command.CommandContext) error {
 		mappedArg = args
 	}
 
-	if err := config.CanChange(cmdctx.ChannelSettings.InUse, cmdctx.Args); err != nil {
+	if err := config.CanChange(cmdctx.Args); err != nil {
 		return err
 	}
 
@@ -70,6 +91,26 @@ func clearCommandRun(cmdctx *command.CommandContext) error {
 		cmdctx.ChannelSettings.Upscaler = ""
 	case "upscaleamount":
 		cmdctx.ChannelSettings.UpscaleAmount = 0
+	case "seed":
+		cmdctx.ChannelSettings.Seed = -1
+	case "batch":
+		cmdctx.ChannelSettings.BatchSize = 1
+	case "facefix":
+		cmdctx.ChannelSettings.FaceRestorer = ""
+	case "tiling":
+		cmdctx.ChannelSettings.Tiling = ""
+	case "format":
+		cmdctx.ChannelSettings.OutputFormat = "png"
+	case "quality":
+		cmdctx.ChannelSettings.OutputQuality = 75
+	case "dynamicprompts":
+		cmdctx.ChannelSettings.CombinatorialPrompts = false
+	case "lora":
+		cmdctx.ChannelSettings.Loras = nil
+	case "controlnet":
+		cmdctx.ChannelSettings.ControlNet = ""
+		cmdctx.ChannelSettings.ControlNetWeight = 0
+		cmdctx.ChannelSettings.ControlImage = ""
 	default:
 		config.ConfigMutex.Unlock()
 		return ErrInvalidProperty
@@ -4,25 +4,23 @@ package command
 import (
 	"fmt"
 	"strings"
-	"sync"
 	"sync/atomic"
 
+	"github.com/diamondburned/arikawa/v3/api"
 	"github.com/diamondburned/arikawa/v3/discord"
+	"github.com/diamondburned/arikawa/v3/utils/json/option"
 )
 
-type CurrentRenderInfo struct {
-	RequestedBy  discord.UserID
-	Task         int64
-	LastFrameUrl string
-	FrameData    []byte
+type Lora struct {
+	Name   string
+	Weight float64
 }
 
 type ChannelSettings struct {
-	InUse *atomic.Bool
-
 	Model        string
 	VAE          string
 	HyperNetwork string
+	Loras        []Lora
 
 	Prompt         string
 	NegativePrompt string
@@ -36,10 +34,24 @@ type ChannelSettings struct {
 	Sampler        string
 	Upscaler       string
 	UpscaleAmount  uint
-
-	CurrentRenderInfo      *CurrentRenderInfo
-	CurrentRenderInfoMutex sync.Mutex
-	SessionID              string
+	FaceRestorer   string
+	BatchSize      uint
+	// "png", "jpeg" or "webp"
+	OutputFormat  string
+	OutputQuality uint
+	// Empty, "x", "y" or "xy"
+	Tiling string
+	// Negative for a random seed on every render
+	Seed int
+	// Render every combination of {a|b} groups in the prompt instead of picking one at random
+	CombinatorialPrompts bool
+
+	ControlNet       string
+	ControlNetWeight float64
+	// Key of the stored image guiding ControlNet renders, or the data URL itself in settings saved before they were stored separately
+	ControlImage string
+
+	SessionID string
 }
 
 type CommandContext struct {
@@ -51,26 +63,116 @@ type CommandContext struct {
 	CalledWithAlias  string
 	Args             string
 	StopTyping       chan<- struct{}
+
+	// Set when the command was invoked as a slash command, Message is then only partially filled
+	Interaction *discord.InteractionEvent
+	responded   atomic.Bool
+
+	stoppedTyping atomic.Bool
+
+	// Saves the channel settings and lets other commands of the channel change them, set by whoever runs the command
+	ReleaseSettings  func()
+	settingsReleased atomic.Bool
 }
 
 func (c *CommandContext) TryReply(format string, a ...any) (msg *discord.Message, err error) {
 	content := fmt.Sprintf(format, a...)
 	content = strings.ReplaceAll(content, "@", "@\u200b") // Just in case
+	return c.Reply(content)
+}
+
+// Replies without escaping mentions
+func (c *CommandContext) Reply(content string) (msg *discord.Message, err error) {
 	if len(content) > 2000 {
 		content = content[:2000]
 	}
 
-	msg, err = c.Executor.SendMessageReply(c.Message.ChannelID, content, c.Message.ID)
+	if c.Interaction != nil {
+		msg, err = c.replyInteraction(content)
+	} else {
+		msg, err = c.Executor.SendMessageReply(c.Message.ChannelID, content, c.Message.ID)
+	}
+
 	if err != nil {
 		msg, err = c.Executor.SendMessage(c.Message.ChannelID, content)
 	}
 	return msg, err
 }
 
+// Replies with an embed the same way Reply does with text
+func (c *CommandContext) ReplyEmbed(embed discord.Embed) (*discord.Message, error) {
+	return c.ReplyEmbedComponents(embed, &discord.ContainerComponents{})
+}
+
+// Replies with an embed that has components, like buttons, under it
+func (c *CommandContext) ReplyEmbedComponents(embed discord.Embed, components *discord.ContainerComponents) (msg *discord.Message, err error) {
+	if c.Interaction != nil {
+		if c.responded.CompareAndSwap(false, true) {
+			msg, err = c.Executor.EditInteractionResponse(c.Interaction.AppID, c.Interaction.Token, api.EditInteractionResponseData{
+				Embeds:     &[]discord.Embed{embed},
+				Components: components,
+			})
+		} else {
+			msg, err = c.Executor.FollowUpInteraction(c.Interaction.AppID, c.Interaction.Token, api.InteractionResponseData{
+				Embeds:     &[]discord.Embed{embed},
+				Components: components,
+			})
+		}
+	} else {
+		msg, err = c.Executor.SendMessageComplex(c.Message.ChannelID, api.SendMessageData{
+			Embeds:     []discord.Embed{embed},
+			Components: *components,
+			Reference:  &discord.MessageReference{MessageID: c.Message.ID},
+		})
+	}
+
+	if err != nil {
+		msg, err = c.Executor.SendMessageComplex(c.Message.ChannelID, api.SendMessageData{
+			Embeds:     []discord.Embed{embed},
+			Components: *components,
+		})
+	}
+	return msg, err
+}
+
+// The first reply replaces the deferred response, the rest are follow-ups
+func (c *CommandContext) replyInteraction(content string) (*discord.Message, error) {
+	if c.responded.CompareAndSwap(false, true) {
+		return c.Executor.EditInteractionResponse(c.Interaction.AppID, c.Interaction.Token, api.EditInteractionResponseData{
+			Content: option.NewNullableString(content),
+		})
+	}
+
+	return c.Executor.FollowUpInteraction(c.Interaction.AppID, c.Interaction.Token, api.InteractionResponseData{
+		Content: option.NewNullableString(content),
+	})
+}
+
+// Whether the deferred interaction response has been replaced yet
+func (c *CommandContext) Responded() bool {
+	return c.responded.Load()
+}
+
+// Stops the typing indicator, several renders of one command can all call this
+func (c *CommandContext) StopTypingOnce() {
+	if c.stoppedTyping.CompareAndSwap(false, true) {
+		c.StopTyping <- struct{}{}
+	}
+}
+
+// Called once the command is done with the channel settings, renders do this before waiting in the queue
+func (c *CommandContext) DoneWithSettings() {
+	if c.ReleaseSettings != nil && c.settingsReleased.CompareAndSwap(false, true) {
+		c.ReleaseSettings()
+	}
+}
+
 type Command struct {
-	Name    string
-	Aliases []string
-	run     func(*CommandContext) error
+	Name        string
+	Aliases     []string
+	Description string
+	Options     []Option
+	run         func(*CommandContext) error
 }
 
 func NewCommand(name string, aliases []string, run func(*CommandContext) error) *Command {
@@ -81,6 +183,42 @@ func NewCommand(name string, aliases []string, run func(*CommandContext) error)
 	}
 }
 
+// Sets what is shown for the command in Discord's slash command picker
+func (c *Command) Describe(description string, options ...Option) *Command {
+	c.Description = description
+	c.Options = options
+	return c
+}
+
+func (c *Command) Option(name string) *Option {
+	for i := range c.Options {
+		if c.Options[i].Name == name {
+			return &c.Options[i]
+		}
+	}
+
+	return nil
+}
+
+func (c *Command) CreateCommandData() api.CreateCommandData {
+	description := c.Description
+	if description == "" {
+		description = c.Name
+	}
+
+	data := api.CreateCommandData{
+		Name:        c.Name,
+		Description: description,
+		Options:     discord.CommandOptions{},
+	}
+
+	for i := range c.Options {
+		data.Options = append(data.Options, c.Options[i].commandOption())
+	}
+
+	return data
+}
+
 func (c *Command) Run(cmdctx *CommandContext) error {
 	return c.run(cmdctx)
 }
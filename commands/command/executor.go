@@ -30,12 +30,24 @@ func (e *Executor) RegisterCommand(cmd *Command) {
 	e.commands = append(e.commands, cmd)
 }
 
-func (e *Executor) RunCommand(name string, cmdctx *CommandContext) error {
+func (e *Executor) GetCommands() []*Command {
+	return e.commands
+}
+
+func (e *Executor) FindCommand(name string) *Command {
 	for _, cmd := range e.commands {
 		if cmd.Name == name || utils.Contains(cmd.Aliases, name) {
-			return cmd.Run(cmdctx)
+			return cmd
 		}
 	}
 
+	return nil
+}
+
+func (e *Executor) RunCommand(name string, cmdctx *CommandContext) error {
+	if cmd := e.FindCommand(name); cmd != nil {
+		return cmd.Run(cmdctx)
+	}
+
 	return ErrCommandNotFound
 }
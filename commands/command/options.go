@@ -0,0 +1,94 @@
+package command
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/diamondburned/arikawa/v3/api"
+	"github.com/diamondburned/arikawa/v3/discord"
+)
+
+// Discord refuses autocomplete responses with more choices than this
+const maxChoices = 25
+
+type OptionType int
+
+const (
+	StringOption OptionType = iota
+	IntegerOption
+	NumberOption
+	AttachmentOption
+)
+
+// A typed argument of a slash command, the values are joined back into Args in the order they are declared
+type Option struct {
+	Name        string
+	Description string
+	Type        OptionType
+	Required    bool
+
+	// Fixed choices, only used when there are at most 25 of them
+	Choices []string
+	// Called to suggest values while the user is typing
+	Autocomplete func() ([]string, error)
+}
+
+func (o *Option) commandOption() discord.CommandOptionValue {
+	switch o.Type {
+	case IntegerOption:
+		opt := &discord.IntegerOption{OptionName: o.Name, Description: o.Description, Required: o.Required}
+		for _, c := range o.Choices {
+			if i, err := strconv.Atoi(c); err == nil {
+				opt.Choices = append(opt.Choices, discord.IntegerChoice{Name: c, Value: i})
+			}
+		}
+
+		return opt
+	case NumberOption:
+		return &discord.NumberOption{OptionName: o.Name, Description: o.Description, Required: o.Required}
+	case AttachmentOption:
+		return &discord.AttachmentOption{OptionName: o.Name, Description: o.Description, Required: o.Required}
+	default:
+		opt := &discord.StringOption{
+			OptionName:   o.Name,
+			Description:  o.Description,
+			Required:     o.Required,
+			Autocomplete: o.Autocomplete != nil,
+		}
+
+		if o.Autocomplete == nil && len(o.Choices) <= maxChoices {
+			for _, c := range o.Choices {
+				opt.Choices = append(opt.Choices, discord.StringChoice{Name: c, Value: c})
+			}
+		}
+
+		return opt
+	}
+}
+
+// Suggestions containing what the user typed so far
+func (o *Option) Complete(typed string) api.AutocompleteStringChoices {
+	choices := api.AutocompleteStringChoices{}
+	values := o.Choices
+	if o.Autocomplete != nil {
+		var err error
+		if values, err = o.Autocomplete(); err != nil {
+			return choices
+		}
+	}
+
+	typed = strings.ToLower(typed)
+	for _, v := range values {
+		if len(choices) >= maxChoices {
+			break
+		}
+
+		if len(v) > 100 || !strings.Contains(strings.ToLower(v), typed) {
+			continue
+		}
+
+		choices = append(choices, discord.StringChoice{Name: v, Value: v})
+	}
+
+	return choices
+}
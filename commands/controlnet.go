@@ -0,0 +1,96 @@
+package commands
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/ayunami2000/ayunsdcord/commands/command"
+	"github.com/ayunami2000/ayunsdcord/commands/render"
+	"github.com/ayunami2000/ayunsdcord/config"
+	"github.com/ayunami2000/ayunsdcord/sdapi"
+	"github.com/ayunami2000/ayunsdcord/utils"
+)
+
+var ControlNetCommand = command.NewCommand("controlnet", []string{"cn"}, controlNetRun).Describe("Shows or sets the ControlNet model and control image",
+	command.Option{Name: "model", Description: "ControlNet model to use, or off", Type: command.StringOption, Autocomplete: controlNetNames},
+	command.Option{Name: "weight", Description: "How strongly the control image guides the render", Type: command.NumberOption},
+	command.Option{Name: "image", Description: "Control image, e.g. a pose, depth map or edges", Type: command.AttachmentOption})
+var ErrInvalidControlNet = errors.New("invalid ControlNet model")
+var ErrNoControlImage = errors.New("please attach a control image")
+
+const defaultControlNetWeight = 1
+
+func controlNetRun(cmdctx *command.CommandContext) error {
+	settings := cmdctx.ChannelSettings
+	if cmdctx.Args == "" && len(cmdctx.Message.Attachments) == 0 {
+		content := "**Current ControlNet:** " + utils.StringOrNone(settings.ControlNet)
+		if settings.ControlNet != "" {
+			content += "\n**Weight:** " + strconv.FormatFloat(settings.ControlNetWeight, 'g', -1, 64)
+		}
+
+		_, err := cmdctx.TryReply("%s", content)
+		return err
+	}
+
+	if err := config.CanChange("controlnet"); err != nil {
+		return err
+	}
+
+	if strings.EqualFold(cmdctx.Args, "off") || strings.EqualFold(cmdctx.Args, "none") {
+		settings.ControlNet = ""
+		settings.ControlNetWeight = 0
+		settings.ControlImage = ""
+
+		_, err := cmdctx.TryReply("**ControlNet disabled**")
+		return err
+	}
+
+	weight := settings.ControlNetWeight
+	if settings.ControlNet == "" {
+		weight = defaultControlNetWeight
+	}
+
+	// Model names can contain spaces, so the weight is only the last word if it is a number
+	name := cmdctx.Args
+	i := strings.LastIndexByte(name, ' ')
+	if f, err := strconv.ParseFloat(name[i+1:], 64); err == nil && i != -1 {
+		name = strings.TrimSpace(name[:i+1])
+		weight = math.Min(math.Max(f, 0), 2)
+	}
+
+	model := settings.ControlNet
+	if name != "" {
+		res, err := sdapi.GetModels()
+		if err != nil {
+			return err
+		}
+
+		if model = findFold(res.Options.ControlNet, name); model == "" {
+			return ErrInvalidControlNet
+		}
+	}
+
+	image := settings.ControlImage
+	attachments := cmdctx.Message.Attachments
+	if len(attachments) > 0 && strings.HasPrefix(attachments[0].ContentType, "image/") {
+		var err error
+		if image, err = render.LoadControlImage(attachments[0].URL, settings.Width, settings.Height); err != nil {
+			return err
+		}
+	}
+
+	if model == "" {
+		return ErrInvalidControlNet
+	} else if image == "" {
+		return ErrNoControlImage
+	}
+
+	settings.ControlNet = model
+	settings.ControlNetWeight = weight
+	settings.ControlImage = image
+
+	_, err := cmdctx.TryReply("**ControlNet set to:** %s (weight %g)", model, weight)
+	return err
+}
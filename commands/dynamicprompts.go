@@ -0,0 +1,44 @@
+package commands
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/ayunami2000/ayunsdcord/commands/command"
+	"github.com/ayunami2000/ayunsdcord/config"
+)
+
+var DynamicPromptsCommand = command.NewCommand("dynamicprompts", []string{"dp"}, dynamicPromptsRun).Describe("Shows or sets how {a|b} groups in prompts are rendered",
+	command.Option{Name: "mode", Description: "Pick one option at random, or render every combination", Type: command.StringOption, Choices: []string{"random", "combinatorial"}})
+var ErrInvalidDynamicPromptsMode = errors.New("invalid mode, use random or combinatorial")
+
+func dynamicPromptsModeText(combinatorial bool) string {
+	if combinatorial {
+		return "combinatorial"
+	}
+
+	return "random"
+}
+
+func dynamicPromptsRun(cmdctx *command.CommandContext) error {
+	if cmdctx.Args == "" {
+		_, err := cmdctx.TryReply("**Current dynamic prompts mode:** %s", dynamicPromptsModeText(cmdctx.ChannelSettings.CombinatorialPrompts))
+		return err
+	}
+
+	if err := config.CanChange("dynamicprompts"); err != nil {
+		return err
+	}
+
+	switch strings.ToLower(cmdctx.Args) {
+	case "random":
+		cmdctx.ChannelSettings.CombinatorialPrompts = false
+	case "combinatorial":
+		cmdctx.ChannelSettings.CombinatorialPrompts = true
+	default:
+		return ErrInvalidDynamicPromptsMode
+	}
+
+	_, err := cmdctx.TryReply("**Dynamic prompts mode set to:** %s", dynamicPromptsModeText(cmdctx.ChannelSettings.CombinatorialPrompts))
+	return err
+}
@@ -0,0 +1,49 @@
+package commands
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/ayunami2000/ayunsdcord/commands/command"
+	"github.com/ayunami2000/ayunsdcord/config"
+	"github.com/ayunami2000/ayunsdcord/sdapi"
+	"github.com/ayunami2000/ayunsdcord/utils"
+)
+
+var FaceFixCommand = command.NewCommand("facefix", []string{"ff"}, faceFixRun).Describe("Shows or sets the face restoration model",
+	command.Option{Name: "model", Description: "Face restoration model to use, or off", Type: command.StringOption, Autocomplete: sdapi.GetFaceRestorers})
+var ErrInvalidFaceRestorer = errors.New("invalid face restoration model")
+
+func faceFixRun(cmdctx *command.CommandContext) error {
+	restorers, err := sdapi.GetFaceRestorers()
+	if err != nil {
+		return err
+	}
+
+	if cmdctx.Args == "" {
+		_, err := cmdctx.TryReply(`**Current face restoration:** %s
+Models: %s`, utils.StringOrNone(cmdctx.ChannelSettings.FaceRestorer), utils.StringOrNone(strings.Join(restorers, ", ")))
+		return err
+	}
+
+	if err := config.CanChange("facefix"); err != nil {
+		return err
+	}
+
+	if strings.EqualFold(cmdctx.Args, "off") || strings.EqualFold(cmdctx.Args, "none") {
+		cmdctx.ChannelSettings.FaceRestorer = ""
+
+		_, err := cmdctx.TryReply("**Face restoration disabled**")
+		return err
+	}
+
+	restorer := findFold(restorers, cmdctx.Args)
+	if restorer == "" {
+		return ErrInvalidFaceRestorer
+	}
+
+	cmdctx.ChannelSettings.FaceRestorer = restorer
+
+	_, err = cmdctx.TryReply("**Face restoration set to:** %s", restorer)
+	return err
+}
@@ -0,0 +1,49 @@
+package commands
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/ayunami2000/ayunsdcord/commands/command"
+	"github.com/ayunami2000/ayunsdcord/config"
+	"github.com/ayunami2000/ayunsdcord/sdapi"
+	"github.com/ayunami2000/ayunsdcord/utils"
+)
+
+var FormatCommand = command.NewCommand("format", []string{"fmt"}, formatRun).Describe("Shows or sets the image format of renders",
+	command.Option{Name: "format", Description: "Image format", Type: command.StringOption, Choices: []string{"png", "jpeg", "webp"}})
+var ErrInvalidFormat = errors.New("invalid format, valid formats: png, jpeg, webp")
+var ErrUnsupportedFormat = errors.New("the backend can't render in that format")
+
+func formatRun(cmdctx *command.CommandContext) error {
+	if cmdctx.Args == "" {
+		_, err := cmdctx.TryReply("**Current format:** %s", cmdctx.ChannelSettings.OutputFormat)
+		return err
+	}
+
+	if err := config.CanChange("format"); err != nil {
+		return err
+	}
+
+	format := strings.ToLower(cmdctx.Args)
+	if format == "jpg" {
+		format = "jpeg"
+	}
+
+	if format != "png" && format != "jpeg" && format != "webp" {
+		return ErrInvalidFormat
+	}
+
+	formats, err := sdapi.GetOutputFormats()
+	if err != nil {
+		return err
+	}
+
+	if !utils.Contains(formats, format) {
+		return ErrUnsupportedFormat
+	}
+
+	cmdctx.ChannelSettings.OutputFormat = format
+	_, err = cmdctx.TryReply("**Format set to:** %s", format)
+	return err
+}
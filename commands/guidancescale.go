@@ -8,7 +8,8 @@ import (
 	"github.com/ayunami2000/ayunsdcord/config"
 )
 
-var GuidanceScaleCommand = command.NewCommand("guidancescale", []string{"gs"}, guidanceScaleRun)
+var GuidanceScaleCommand = command.NewCommand("guidancescale", []string{"gs"}, guidanceScaleRun).Describe("Shows or sets the guidance scale",
+	command.Option{Name: "scale", Description: "How closely to follow the prompt", Type: command.NumberOption})
 
 func guidanceScaleRun(cmdctx *command.CommandContext) error {
 	if cmdctx.Args == "" {
@@ -16,7 +17,7 @@ func guidanceScaleRun(cmdctx *command.CommandContext) error {
 		return err
 	}
 
-	if err := config.CanChange(cmdctx.ChannelSettings.InUse, "guidancescale"); err != nil {
+	if err := config.CanChange("guidancescale"); err != nil {
 		return err
 	}
 
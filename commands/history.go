@@ -0,0 +1,161 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ayunami2000/ayunsdcord/commands/command"
+	"github.com/ayunami2000/ayunsdcord/commands/render"
+	"github.com/ayunami2000/ayunsdcord/utils"
+	"github.com/diamondburned/arikawa/v3/discord"
+)
+
+var HistoryCommand = command.NewCommand("history", []string{"hi"}, historyRun).Describe("Shows the latest renders of a user",
+	command.Option{Name: "user", Description: "User to show the renders of, defaults to you", Type: command.StringOption},
+	command.Option{Name: "count", Description: "Number of renders, from 1 to 100", Type: command.IntegerOption})
+var ErrInvalidHistoryArgs = errors.New("usage: history [user] [count]")
+
+// Every page button of a history embed has an ID starting with this
+const HistoryButtonPrefix = "history:"
+
+const (
+	defaultHistoryCount = 25
+	maxHistoryCount     = 100
+	historyPageSize     = 5
+)
+
+var userMentionRegex = regexp.MustCompile(`^<@!?(\d+)>$|^(\d{15,})$`)
+
+func historyRun(cmdctx *command.CommandContext) error {
+	userID := cmdctx.Message.Author.ID
+	count := defaultHistoryCount
+	for _, arg := range strings.Fields(cmdctx.Args) {
+		if match := userMentionRegex.FindStringSubmatch(arg); match != nil {
+			id, err := discord.ParseSnowflake(match[1] + match[2])
+			if err != nil {
+				return err
+			}
+
+			userID = discord.UserID(id)
+			continue
+		}
+
+		i, err := strconv.Atoi(arg)
+		if err != nil || i < 1 || i > maxHistoryCount {
+			return ErrInvalidHistoryArgs
+		}
+
+		count = i
+	}
+
+	embed, components, err := historyPage(cmdctx.Message.Author.ID, cmdctx.Message.GuildID, userID, count, 0)
+	if err != nil {
+		return err
+	}
+
+	_, err = cmdctx.ReplyEmbedComponents(embed, components)
+	return err
+}
+
+// Handles a click on the page buttons of a history embed, returning the page to replace it with
+func HistoryButton(viewer discord.UserID, guildID discord.GuildID, customID string) (discord.Embed, *discord.ContainerComponents, error) {
+	split := strings.Split(strings.TrimPrefix(customID, HistoryButtonPrefix), ":")
+	if len(split) != 3 {
+		return discord.Embed{}, nil, ErrInvalidHistoryArgs
+	}
+
+	userID, err := discord.ParseSnowflake(split[0])
+	if err != nil {
+		return discord.Embed{}, nil, err
+	}
+
+	count, err := strconv.Atoi(split[1])
+	if err != nil {
+		return discord.Embed{}, nil, err
+	}
+
+	page, err := strconv.Atoi(split[2])
+	if err != nil {
+		return discord.Embed{}, nil, err
+	}
+
+	return historyPage(viewer, guildID, discord.UserID(userID), count, page)
+}
+
+func historyButton(label string, userID discord.UserID, count int, page int, disabled bool) *discord.ButtonComponent {
+	return &discord.ButtonComponent{
+		Label:    label,
+		CustomID: discord.ComponentID(fmt.Sprintf("%s%s:%d:%d", HistoryButtonPrefix, userID, count, page)),
+		Style:    discord.SecondaryButtonStyle(),
+		Disabled: disabled,
+	}
+}
+
+func historyEntryText(number int, entry *render.HistoryEntry) string {
+	prompt := strings.NewReplacer("[", "(", "]", ")", "\n", " ").Replace(utils.TruncateText(entry.Data.Prompt, 80))
+	if prompt == "" {
+		prompt = "(no prompt)"
+	}
+
+	text := fmt.Sprintf("**%d.** <t:%d:R> [%s](%s)\n%s in %s, seed %d", number, entry.Started.Unix(), prompt, entry.URL(), entry.Outcome, entry.Duration.Round(100*time.Millisecond), entry.Data.Seed)
+	if entry.Backend != "" {
+		text += " on " + entry.Backend
+	}
+	// Attachment URLs are long, so only the first is linked to keep the page within the embed limit
+	if images := entry.Images(); len(images) == 1 {
+		text += fmt.Sprintf(", [image](%s)", images[0])
+	} else if len(images) > 1 {
+		text += fmt.Sprintf(", [%d images](%s)", len(images), images[0])
+	}
+	if entry.Error != "" {
+		text += "\n**Error:** " + utils.TruncateText(entry.Error, 120)
+	}
+
+	return text
+}
+
+// One page of the latest renders of a user, others only see those made in the same server
+func historyPage(viewer discord.UserID, guildID discord.GuildID, userID discord.UserID, count int, page int) (discord.Embed, *discord.ContainerComponents, error) {
+	entries, err := render.History(userID, guildID, count, viewer == userID)
+	if err != nil {
+		return discord.Embed{}, nil, err
+	}
+
+	pages := (len(entries) + historyPageSize - 1) / historyPageSize
+	if pages < 1 {
+		pages = 1
+	}
+	if page < 0 || page >= pages {
+		page = 0
+	}
+
+	lines := []string{}
+	for i := page * historyPageSize; i < len(entries) && i < (page+1)*historyPageSize; i++ {
+		lines = append(lines, historyEntryText(i+1, &entries[i]))
+	}
+
+	desc := fmt.Sprintf("**User:** %s\n\n", userID.Mention())
+	if len(lines) == 0 {
+		desc += "No renders yet."
+	} else {
+		desc += strings.Join(lines, "\n\n")
+	}
+
+	embed := discord.Embed{
+		Title:       "Render History",
+		Description: desc,
+		Footer:      &discord.EmbedFooter{Text: fmt.Sprintf("Page %d of %d", page+1, pages)},
+		Timestamp:   discord.NewTimestamp(time.Now()),
+	}
+
+	components := &discord.ContainerComponents{&discord.ActionRowComponent{
+		historyButton("Previous", userID, count, page-1, page == 0),
+		historyButton("Next", userID, count, page+1, page >= pages-1),
+	}}
+
+	return embed, components, nil
+}
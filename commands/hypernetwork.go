@@ -10,7 +10,8 @@ import (
 	"github.com/ayunami2000/ayunsdcord/utils"
 )
 
-var HyperNetworkCommand = command.NewCommand("hypernetwork", []string{"hn"}, hyperNetworkRun)
+var HyperNetworkCommand = command.NewCommand("hypernetwork", []string{"hn"}, hyperNetworkRun).Describe("Shows or sets the HyperNetwork",
+	command.Option{Name: "name", Description: "HyperNetwork to use", Type: command.StringOption, Autocomplete: hyperNetworkNames})
 var ErrInvalidHyperNetwork = errors.New("invalid HyperNetwork")
 
 func hyperNetworkRun(cmdctx *command.CommandContext) error {
@@ -19,7 +20,7 @@ func hyperNetworkRun(cmdctx *command.CommandContext) error {
 		return err
 	}
 
-	if err := config.CanChange(cmdctx.ChannelSettings.InUse, "hypernetwork"); err != nil {
+	if err := config.CanChange("hypernetwork"); err != nil {
 		return err
 	}
 
@@ -0,0 +1,255 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ayunami2000/ayunsdcord/commands/command"
+	"github.com/ayunami2000/ayunsdcord/config"
+	"github.com/ayunami2000/ayunsdcord/sdapi"
+	"github.com/ayunami2000/ayunsdcord/utils"
+)
+
+var ImportCommand = command.NewCommand("import", []string{"im"}, importRun).Describe("Imports the settings of an image made with another tool",
+	command.Option{Name: "file", Description: "PNG with embedded parameters, or an Easy Diffusion .txt/.json file", Type: command.AttachmentOption, Required: true})
+var ErrNoAttachment = errors.New("please attach an image or metadata file")
+var ErrNotAvailable = errors.New("not available")
+
+// Metadata files are small, anything larger than this is not worth downloading
+const maxImportSize = 32 << 20
+
+func downloadAttachment(url string) ([]byte, error) {
+	res, err := http.Get(url)
+	if err != nil {
+		return nil, err
+	}
+
+	defer res.Body.Close()
+	return io.ReadAll(io.LimitReader(res.Body, maxImportSize))
+}
+
+func readParameters(raw []byte) (*sdapi.Parameters, error) {
+	texts, err := utils.GetPNGTexts(raw)
+	if errors.Is(err, utils.ErrNotPNG) {
+		values, err := sdapi.ReadEasyDiffusionSidecar(raw)
+		if err != nil {
+			return nil, err
+		}
+
+		return sdapi.ParseEasyDiffusionMetadata(values), nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	if parameters, exists := texts["parameters"]; exists {
+		return sdapi.ParseParameters(parameters), nil
+	}
+
+	// Easy Diffusion can embed its metadata as one text chunk per field
+	for keyword := range texts {
+		if strings.EqualFold(keyword, "prompt") {
+			return sdapi.ParseEasyDiffusionMetadata(texts), nil
+		}
+	}
+
+	return nil, sdapi.ErrNoMetadata
+}
+
+func findFold(options []string, names ...string) string {
+	for _, o := range options {
+		for _, n := range names {
+			if strings.EqualFold(o, n) {
+				return o
+			}
+		}
+	}
+
+	return ""
+}
+
+// Applies a single field in the webui format to the settings, fields without an importer are reported as unmapped
+var importers = map[string]func(settings *command.ChannelSettings, value string) error{
+	"Steps": func(settings *command.ChannelSettings, value string) error {
+		if err := config.CanChange("inferencesteps"); err != nil {
+			return err
+		}
+
+		i, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			return err
+		}
+
+		settings.InferenceSteps = uint(math.Min(math.Max(float64(i), 1), 100))
+		return nil
+	},
+	"CFG scale": func(settings *command.ChannelSettings, value string) error {
+		if err := config.CanChange("guidancescale"); err != nil {
+			return err
+		}
+
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return err
+		}
+
+		settings.GuidanceScale = math.Min(math.Max(f, 1.1), 50)
+		return nil
+	},
+	"Sampler": func(settings *command.ChannelSettings, value string) error {
+		if err := config.CanChange("sampler"); err != nil {
+			return err
+		}
+
+		samplers, err := sdapi.GetSamplers()
+		if err != nil {
+			return err
+		}
+
+		sampler := findFold(samplers, sdapi.SamplerAliases(value)...)
+		if sampler == "" {
+			return ErrNotAvailable
+		}
+
+		settings.Sampler = sampler
+		return nil
+	},
+	"Seed": func(settings *command.ChannelSettings, value string) error {
+		if err := config.CanChange("seed"); err != nil {
+			return err
+		}
+
+		i, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			return err
+		}
+
+		settings.Seed = int(i)
+		return nil
+	},
+	"Size": func(settings *command.ChannelSettings, value string) error {
+		if err := config.CanChange("size"); err != nil {
+			return err
+		}
+
+		width, height, err := parseSizes(value)
+		if err != nil {
+			return err
+		}
+
+		settings.Width = width
+		settings.Height = height
+		return nil
+	},
+	"Face restoration": func(settings *command.ChannelSettings, value string) error {
+		if err := config.CanChange("facefix"); err != nil {
+			return err
+		}
+
+		restorers, err := sdapi.GetFaceRestorers()
+		if err != nil {
+			return err
+		}
+
+		restorer := findFold(restorers, value)
+		if restorer == "" {
+			return ErrNotAvailable
+		}
+
+		settings.FaceRestorer = restorer
+		return nil
+	},
+	"Tiling": func(settings *command.ChannelSettings, value string) error {
+		if strings.EqualFold(value, "True") {
+			value = "xy"
+		}
+
+		return setTiling(settings, value)
+	},
+	"Model": func(settings *command.ChannelSettings, value string) error {
+		if err := config.CanChange("model"); err != nil {
+			return err
+		}
+
+		models, err := modelNames()
+		if err != nil {
+			return err
+		}
+
+		model := findFold(models, value)
+		if model == "" {
+			return ErrNotAvailable
+		}
+
+		settings.Model = model
+		return nil
+	},
+}
+
+func importRun(cmdctx *command.CommandContext) error {
+	if len(cmdctx.Message.Attachments) < 1 {
+		return ErrNoAttachment
+	}
+
+	raw, err := downloadAttachment(cmdctx.Message.Attachments[0].URL)
+	if err != nil {
+		return err
+	}
+
+	params, err := readParameters(raw)
+	if err != nil {
+		return err
+	}
+
+	imported := []string{}
+	unmapped := []string{}
+
+	if params.Prompt != "" {
+		if err := config.CanChange("prompt"); err != nil {
+			unmapped = append(unmapped, fmt.Sprintf("Prompt (%v)", err))
+		} else {
+			cmdctx.ChannelSettings.Prompt = utils.TruncateText(params.Prompt, 512)
+			imported = append(imported, "Prompt")
+		}
+	}
+
+	if params.NegativePrompt != "" {
+		if err := config.CanChange("negativeprompt"); err != nil {
+			unmapped = append(unmapped, fmt.Sprintf("Negative prompt (%v)", err))
+		} else {
+			cmdctx.ChannelSettings.NegativePrompt = utils.TruncateText(params.NegativePrompt, 512)
+			imported = append(imported, "Negative prompt")
+		}
+	}
+
+	fields := []string{}
+	for field := range params.Fields {
+		fields = append(fields, field)
+	}
+
+	sort.Strings(fields)
+	for _, field := range fields {
+		value := params.Fields[field]
+		importer, exists := importers[field]
+		if !exists {
+			unmapped = append(unmapped, fmt.Sprintf("%s: %s", field, utils.TruncateText(value, 64)))
+		} else if err := importer(cmdctx.ChannelSettings, value); err != nil {
+			unmapped = append(unmapped, fmt.Sprintf("%s: %s (%v)", field, utils.TruncateText(value, 64), err))
+		} else {
+			imported = append(imported, fmt.Sprintf("%s: %s", field, value))
+		}
+	}
+
+	content := fmt.Sprintf("**Imported:** %s", utils.StringOrNone(strings.Join(imported, ", ")))
+	if len(unmapped) > 0 {
+		content += fmt.Sprintf("\n**Could not import:** %s", strings.Join(unmapped, ", "))
+	}
+
+	_, err = cmdctx.TryReply("%s", content)
+	return err
+}
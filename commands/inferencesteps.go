@@ -8,7 +8,8 @@ import (
 	"github.com/ayunami2000/ayunsdcord/config"
 )
 
-var InferenceStepsCommand = command.NewCommand("inferencesteps", []string{"is"}, inferenceStepsRun)
+var InferenceStepsCommand = command.NewCommand("inferencesteps", []string{"is"}, inferenceStepsRun).Describe("Shows or sets the number of inference steps",
+	command.Option{Name: "steps", Description: "Number of steps", Type: command.IntegerOption})
 
 func inferenceStepsRun(cmdctx *command.CommandContext) error {
 	if cmdctx.Args == "" {
@@ -16,7 +17,7 @@ func inferenceStepsRun(cmdctx *command.CommandContext) error {
 		return err
 	}
 
-	if err := config.CanChange(cmdctx.ChannelSettings.InUse, "inferencesteps"); err != nil {
+	if err := config.CanChange("inferencesteps"); err != nil {
 		return err
 	}
 
@@ -7,7 +7,7 @@ import (
 	"github.com/ayunami2000/ayunsdcord/sdapi"
 )
 
-var ListModelsCommand = command.NewCommand("listmodels", []string{"lm"}, listModelsCommandRun)
+var ListModelsCommand = command.NewCommand("listmodels", []string{"lm"}, listModelsCommandRun).Describe("Lists the available models")
 
 func listModelsCommandRun(cmdctx *command.CommandContext) error {
 	res, err := sdapi.GetModels()
@@ -18,10 +18,14 @@ func listModelsCommandRun(cmdctx *command.CommandContext) error {
 	_, err = cmdctx.TryReply(`**Models:**
 __Stable Diffusion__: %s
 __VAE__: %s
-__HyperNetwork__: %s`,
+__HyperNetwork__: %s
+__LoRA__: %s
+__ControlNet__: %s`,
 		strings.Join(res.Options.StableDiffusion, ", "),
 		strings.Join(res.Options.VAE, ", "),
-		strings.Join(res.Options.HyperNetwork, ", "))
+		strings.Join(res.Options.HyperNetwork, ", "),
+		strings.Join(res.Options.Lora, ", "),
+		strings.Join(res.Options.ControlNet, ", "))
 
 	return err
 }
@@ -0,0 +1,89 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/ayunami2000/ayunsdcord/commands/command"
+	"github.com/ayunami2000/ayunsdcord/commands/render"
+	"github.com/ayunami2000/ayunsdcord/config"
+	"github.com/ayunami2000/ayunsdcord/utils"
+)
+
+var LoraCommand = command.NewCommand("lora", []string{"lo"}, loraRun).Describe("Lists, adds or removes the LoRAs used for every render",
+	command.Option{Name: "action", Description: "What to do", Type: command.StringOption, Choices: []string{"list", "add", "remove"}},
+	command.Option{Name: "name", Description: "LoRA to add or remove", Type: command.StringOption, Autocomplete: loraNames},
+	command.Option{Name: "weight", Description: "How strongly the LoRA is applied", Type: command.NumberOption})
+var ErrInvalidLoraAction = errors.New("invalid action, use list, add or remove")
+var ErrLoraNotUsed = errors.New("that LoRA is not used")
+
+func loraListText(loras []command.Lora) string {
+	list := []string{}
+	for _, l := range loras {
+		list = append(list, fmt.Sprintf("%s (%g)", l.Name, l.Weight))
+	}
+
+	return utils.StringOrNone(strings.Join(list, ", "))
+}
+
+func loraRun(cmdctx *command.CommandContext) error {
+	settings := cmdctx.ChannelSettings
+	action, rest, _ := strings.Cut(strings.TrimSpace(cmdctx.Args), " ")
+	if action == "" || strings.EqualFold(action, "list") {
+		_, err := cmdctx.TryReply("**Current LoRAs:** %s\nLoRAs can also be used in the prompt with `<lora:name:weight>`", loraListText(settings.Loras))
+		return err
+	}
+
+	if err := config.CanChange("lora"); err != nil {
+		return err
+	}
+
+	// Names can contain spaces, so the weight is only the last word if it is a number
+	weight := 1.0
+	i := strings.LastIndexByte(rest, ' ')
+	if f, err := strconv.ParseFloat(rest[i+1:], 64); err == nil && i != -1 {
+		rest = rest[:i]
+		weight = math.Min(math.Max(f, -2), 2)
+	}
+
+	switch strings.ToLower(action) {
+	case "add":
+		name, err := render.FindLora(rest)
+		if err != nil {
+			return err
+		}
+
+		found := false
+		for i := range settings.Loras {
+			if settings.Loras[i].Name == name {
+				settings.Loras[i].Weight = weight
+				found = true
+			}
+		}
+
+		if !found {
+			settings.Loras = append(settings.Loras, command.Lora{Name: name, Weight: weight})
+		}
+	case "remove":
+		loras := []command.Lora{}
+		for _, l := range settings.Loras {
+			if !strings.EqualFold(l.Name, strings.TrimSpace(rest)) {
+				loras = append(loras, l)
+			}
+		}
+
+		if len(loras) == len(settings.Loras) {
+			return ErrLoraNotUsed
+		}
+
+		settings.Loras = loras
+	default:
+		return ErrInvalidLoraAction
+	}
+
+	_, err := cmdctx.TryReply("**LoRAs set to:** %s", loraListText(settings.Loras))
+	return err
+}
@@ -9,7 +9,8 @@ import (
 	"github.com/ayunami2000/ayunsdcord/sdapi"
 )
 
-var ModelCommand = command.NewCommand("model", []string{"m"}, modelCommandRun)
+var ModelCommand = command.NewCommand("model", []string{"m"}, modelCommandRun).Describe("Shows or sets the model",
+	command.Option{Name: "name", Description: "Model to use", Type: command.StringOption, Autocomplete: modelNames})
 var ErrInvalidModel = errors.New("invalid model")
 
 func modelCommandRun(cmdctx *command.CommandContext) error {
@@ -18,7 +19,7 @@ func modelCommandRun(cmdctx *command.CommandContext) error {
 		return err
 	}
 
-	if err := config.CanChange(cmdctx.ChannelSettings.InUse, "model"); err != nil {
+	if err := config.CanChange("model"); err != nil {
 		return err
 	}
 
@@ -6,7 +6,8 @@ import (
 	"github.com/ayunami2000/ayunsdcord/utils"
 )
 
-var NegativePromptCommand = command.NewCommand("negativeprompt", []string{"np"}, negativePromptCommandRun)
+var NegativePromptCommand = command.NewCommand("negativeprompt", []string{"np"}, negativePromptCommandRun).Describe("Shows or sets the negative prompt",
+	command.Option{Name: "prompt", Description: "What to avoid in the image", Type: command.StringOption})
 
 func negativePromptCommandRun(cmdctx *command.CommandContext) error {
 	if cmdctx.Args == "" {
@@ -14,7 +15,7 @@ func negativePromptCommandRun(cmdctx *command.CommandContext) error {
 		return err
 	}
 
-	if err := config.CanChange(cmdctx.ChannelSettings.InUse, "negativeprompt"); err != nil {
+	if err := config.CanChange("negativeprompt"); err != nil {
 		return err
 	}
 
@@ -0,0 +1,128 @@
+package commands
+
+import (
+	"errors"
+	"math/rand"
+	"strconv"
+	"strings"
+
+	"github.com/ayunami2000/ayunsdcord/commands/command"
+	"github.com/ayunami2000/ayunsdcord/commands/render"
+	"github.com/ayunami2000/ayunsdcord/sdapi"
+)
+
+var OutpaintCommand = command.NewCommand("outpaint", []string{"op"}, outpaintRun).Describe("Extends the last render, or an attached image, in any direction",
+	command.Option{Name: "direction", Description: "Side to extend", Type: command.StringOption, Required: true, Choices: outpaintDirections},
+	command.Option{Name: "pixels", Description: "How far to extend it", Type: command.IntegerOption, Required: true},
+	command.Option{Name: "image", Description: "Image to extend instead of the last render", Type: command.AttachmentOption})
+var ErrInvalidDirection = errors.New("invalid direction, use " + strings.Join(outpaintDirections, ", "))
+var ErrInvalidPixels = errors.New("invalid amount of pixels")
+var ErrOutpaintTooLarge = errors.New("the outpainted image would be larger than the largest size")
+
+var outpaintDirections = []string{"left", "right", "up", "down", "all"}
+
+// Smallest valid size that fits the given one
+func nextValidSize(size int) (int, bool) {
+	for _, s := range VALID_SIZES {
+		if int(s) >= size {
+			return int(s), true
+		}
+	}
+
+	return 0, false
+}
+
+// Grows the padding of one axis until the image is a valid size, on the padded sides or evenly if neither is
+func fitPadding(size int, before int, after int) (int, int, error) {
+	total, ok := nextValidSize(size + before + after)
+	if !ok {
+		return 0, 0, ErrOutpaintTooLarge
+	}
+
+	extra := total - size - before - after
+	if before > 0 && after == 0 {
+		before += extra
+	} else if after > 0 && before == 0 {
+		after += extra
+	} else {
+		before += extra / 2
+		after += extra - extra/2
+	}
+
+	return before, after, nil
+}
+
+// The attached image with the channel settings, or else the render replied to or the last one of the channel with its own settings
+func outpaintSource(cmdctx *command.CommandContext) (string, *sdapi.RenderData, error) {
+	attachments := cmdctx.Message.Attachments
+	if len(attachments) > 0 && strings.HasPrefix(attachments[0].ContentType, "image/") {
+		return attachments[0].URL, render.NewRenderData(cmdctx.ChannelSettings), nil
+	}
+
+	record, err := render.TargetRecord(cmdctx)
+	if err != nil {
+		return "", nil, err
+	}
+
+	imageURL, err := record.Image(0)
+	if err != nil {
+		return "", nil, err
+	}
+
+	data := record.RenderData()
+	data.Seed = int(rand.Int31())
+	// The image is already upscaled, if it was
+	data.UseUpscale = ""
+	data.UpscaleAmount = ""
+	return imageURL, data, nil
+}
+
+func outpaintRun(cmdctx *command.CommandContext) error {
+	args := strings.Fields(strings.ToLower(cmdctx.Args))
+	if len(args) < 2 {
+		_, err := cmdctx.TryReply("**Please specify a direction (%s) and an amount of pixels!**", strings.Join(outpaintDirections, ", "))
+		return err
+	}
+
+	pixels, err := strconv.ParseUint(args[1], 10, 64)
+	if err != nil || pixels == 0 || pixels > VALID_SIZES[len(VALID_SIZES)-1] {
+		return ErrInvalidPixels
+	}
+
+	p := int(pixels)
+	padding := render.Padding{}
+	switch args[0] {
+	case "left":
+		padding.Left = p
+	case "right":
+		padding.Right = p
+	case "up":
+		padding.Top = p
+	case "down":
+		padding.Bottom = p
+	case "all":
+		padding = render.Padding{Left: p, Top: p, Right: p, Bottom: p}
+	default:
+		return ErrInvalidDirection
+	}
+
+	imageURL, data, err := outpaintSource(cmdctx)
+	if err != nil {
+		return err
+	}
+
+	img, err := render.DownloadImage(imageURL)
+	if err != nil {
+		return err
+	}
+
+	if padding.Left, padding.Right, err = fitPadding(img.Bounds().Dx(), padding.Left, padding.Right); err != nil {
+		return err
+	}
+
+	if padding.Top, padding.Bottom, err = fitPadding(img.Bounds().Dy(), padding.Top, padding.Bottom); err != nil {
+		return err
+	}
+
+	return render.Outpaint(cmdctx, img, data, padding)
+}
@@ -6,7 +6,8 @@ import (
 	"github.com/ayunami2000/ayunsdcord/utils"
 )
 
-var PromptCommand = command.NewCommand("prompt", []string{"p"}, promptCommandRun)
+var PromptCommand = command.NewCommand("prompt", []string{"p"}, promptCommandRun).Describe("Shows or sets the prompt",
+	command.Option{Name: "prompt", Description: "What to render", Type: command.StringOption})
 
 func promptCommandRun(cmdctx *command.CommandContext) error {
 	if cmdctx.Args == "" {
@@ -14,7 +15,7 @@ func promptCommandRun(cmdctx *command.CommandContext) error {
 		return err
 	}
 
-	if err := config.CanChange(cmdctx.ChannelSettings.InUse, "prompt"); err != nil {
+	if err := config.CanChange("prompt"); err != nil {
 		return err
 	}
 
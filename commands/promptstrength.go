@@ -8,7 +8,8 @@ import (
 	"github.com/ayunami2000/ayunsdcord/config"
 )
 
-var PromptStrengthCommand = command.NewCommand("promptstrength", []string{"ps"}, promptStrengthRun)
+var PromptStrengthCommand = command.NewCommand("promptstrength", []string{"ps"}, promptStrengthRun).Describe("Shows or sets the Img2Img prompt strength",
+	command.Option{Name: "strength", Description: "How much to change the image", Type: command.NumberOption})
 
 func promptStrengthRun(cmdctx *command.CommandContext) error {
 	if cmdctx.Args == "" {
@@ -16,7 +17,7 @@ func promptStrengthRun(cmdctx *command.CommandContext) error {
 		return err
 	}
 
-	if err := config.CanChange(cmdctx.ChannelSettings.InUse, "promptstrength"); err != nil {
+	if err := config.CanChange("promptstrength"); err != nil {
 		return err
 	}
 
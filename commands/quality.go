@@ -0,0 +1,37 @@
+package commands
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/ayunami2000/ayunsdcord/commands/command"
+	"github.com/ayunami2000/ayunsdcord/config"
+)
+
+var QualityCommand = command.NewCommand("quality", []string{"q"}, qualityRun).Describe("Shows or sets the quality of JPEG and WebP renders",
+	command.Option{Name: "quality", Description: "Quality, from 1 to 100", Type: command.IntegerOption})
+var ErrInvalidQuality = errors.New("invalid quality")
+
+func qualityRun(cmdctx *command.CommandContext) error {
+	if cmdctx.Args == "" {
+		_, err := cmdctx.TryReply("**Current quality:** %d", cmdctx.ChannelSettings.OutputQuality)
+		return err
+	}
+
+	if err := config.CanChange("quality"); err != nil {
+		return err
+	}
+
+	i, err := strconv.ParseUint(cmdctx.Args, 10, 64)
+	if err != nil {
+		return err
+	}
+
+	if i < 1 || i > 100 {
+		return ErrInvalidQuality
+	}
+
+	cmdctx.ChannelSettings.OutputQuality = uint(i)
+	_, err = cmdctx.TryReply("**Quality set to:** %d", cmdctx.ChannelSettings.OutputQuality)
+	return err
+}
@@ -10,7 +10,8 @@ import (
 	"github.com/tjarratt/babble"
 )
 
-var RandomCommand = command.NewCommand("random", []string{"rand", "randomrender", "rr"}, randomCommandRun)
+var RandomCommand = command.NewCommand("random", []string{"rand", "randomrender", "rr"}, randomCommandRun).Describe("Renders a random prompt",
+	command.Option{Name: "words", Description: "Number of random words", Type: command.IntegerOption})
 
 var babbler = babble.NewBabbler()
 
@@ -35,7 +36,7 @@ func randomCommandRun(cmdctx *command.CommandContext) error {
 		}
 	}
 
-	if err := config.CanChange(cmdctx.ChannelSettings.InUse, "prompt"); err != nil {
+	if err := config.CanChange("prompt"); err != nil {
 		return err
 	}
 
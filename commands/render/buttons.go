@@ -0,0 +1,200 @@
+package render
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+
+	"github.com/ayunami2000/ayunsdcord/commands/command"
+	"github.com/ayunami2000/ayunsdcord/config"
+	"github.com/ayunami2000/ayunsdcord/sdapi"
+	"github.com/diamondburned/arikawa/v3/discord"
+)
+
+var ErrNotRequestedByYou = errors.New("render not requested by you")
+var ErrUnknownButton = errors.New("unknown button")
+var ErrNoUpscalers = errors.New("no upscalers available")
+
+// Every button under a render has an ID starting with this
+const ButtonPrefix = "render:"
+
+const (
+	stopButton      = ButtonPrefix + "stop"
+	rerollButton    = ButtonPrefix + "reroll"
+	upscaleButton   = ButtonPrefix + "upscale"
+	variationButton = ButtonPrefix + "variation"
+	img2imgButton   = ButtonPrefix + "img2img"
+)
+
+// Discord allows at most this many buttons in a row
+const buttonsPerRow = 5
+
+func button(label string, id string, index int, style discord.ButtonComponentStyle) *discord.ButtonComponent {
+	return &discord.ButtonComponent{
+		Label:    label,
+		CustomID: discord.ComponentID(fmt.Sprintf("%s:%d", id, index)),
+		Style:    style,
+	}
+}
+
+func progressComponents() *discord.ContainerComponents {
+	return &discord.ContainerComponents{
+		&discord.ActionRowComponent{
+			&discord.ButtonComponent{Label: "Stop", CustomID: stopButton, Style: discord.DangerButtonStyle()},
+		},
+	}
+}
+
+// Batches get a button per image to upscale or vary it, as the grid is what is shown
+func resultComponents(data *sdapi.RenderData) *discord.ContainerComponents {
+	reroll := &discord.ButtonComponent{Label: "Reroll", CustomID: rerollButton, Style: discord.PrimaryButtonStyle()}
+	if data.NumOutputs <= 1 {
+		return &discord.ContainerComponents{&discord.ActionRowComponent{
+			reroll,
+			button("Upscale 2x", upscaleButton+":2", 0, discord.SecondaryButtonStyle()),
+			button("Upscale 4x", upscaleButton+":4", 0, discord.SecondaryButtonStyle()),
+			button("Variation", variationButton, 0, discord.SecondaryButtonStyle()),
+			button("Use as Img2Img", img2imgButton, 0, discord.SecondaryButtonStyle()),
+		}}
+	}
+
+	amount := data.UpscaleAmount
+	if amount == "" {
+		amount = "2"
+	}
+
+	components := discord.ContainerComponents{&discord.ActionRowComponent{reroll}}
+	for _, action := range []struct{ label, id string }{{"U", upscaleButton + ":" + amount}, {"V", variationButton}} {
+		var row discord.ActionRowComponent
+		for i := 0; i < int(data.NumOutputs); i++ {
+			row = append(row, button(fmt.Sprintf("%s%d", action.label, i+1), action.id, i, discord.SecondaryButtonStyle()))
+			if len(row) == buttonsPerRow || i == int(data.NumOutputs)-1 {
+				components = append(components, &row)
+				row = nil
+			}
+		}
+	}
+
+	return &components
+}
+
+// Handles a click on one of the buttons under the render in the given message
+func Button(cmdctx *command.CommandContext, messageID discord.MessageID, customID string) error {
+	if customID == stopButton {
+		return stopButtonRun(cmdctx, messageID)
+	}
+
+	record, err := GetRecord(messageID)
+	if err != nil {
+		return err
+	}
+
+	data := record.RenderData()
+	data.Seed = int(rand.Int31())
+	if customID == rerollButton {
+		return Enqueue(cmdctx, data)
+	}
+
+	// Everything else acts on a single image of the render, given by the last part of the ID
+	split := strings.Split(customID, ":")
+	index, err := strconv.Atoi(split[len(split)-1])
+	if err != nil {
+		return ErrUnknownButton
+	}
+
+	imageURL, err := record.Image(index)
+	if err != nil {
+		return err
+	}
+
+	data.NumOutputs = 1
+
+	switch {
+	case strings.HasPrefix(customID, upscaleButton+":") && len(split) == 4:
+		return upscaleButtonRun(cmdctx, split[2], record, imageURL)
+	case strings.HasPrefix(customID, variationButton+":"):
+		return Variation(cmdctx, record, index, VariationStrength, 1)
+	case strings.HasPrefix(customID, img2imgButton+":"):
+		if err := config.CanChange("img2img"); err != nil {
+			return err
+		}
+
+		if err := loadInitImage(imageURL, data); err != nil {
+			return err
+		}
+
+		data.Mask = ""
+		data.PromptStrength = cmdctx.ChannelSettings.PromptStrength
+	default:
+		return ErrUnknownButton
+	}
+
+	return Enqueue(cmdctx, data)
+}
+
+func stopButtonRun(cmdctx *command.CommandContext, messageID discord.MessageID) error {
+	if err := config.CanChange("stop"); err != nil {
+		return err
+	}
+
+	job := GetJob(messageID)
+	if job == nil {
+		return ErrJobFinished
+	}
+
+	if job.RequestedBy != cmdctx.Message.Author.ID {
+		return ErrNotRequestedByYou
+	}
+
+	if err := job.Stop(); err != nil {
+		return err
+	}
+
+	_, err := cmdctx.TryReply("**Stopped current render**")
+	return err
+}
+
+// Upscales the image as it is with the upscaler of the original render, or of the channel if it had none
+func upscaleButtonRun(cmdctx *command.CommandContext, amountText string, record *Record, imageURL string) error {
+	if err := config.CanChange("upscaleamount"); err != nil {
+		return err
+	}
+
+	amount, err := strconv.ParseUint(amountText, 10, 64)
+	if err != nil {
+		return ErrUnknownButton
+	}
+
+	upscaler := record.Data.UseUpscale
+	if upscaler == "" {
+		upscaler = cmdctx.ChannelSettings.Upscaler
+	}
+
+	if upscaler == "" {
+		upscalers, err := sdapi.GetUpscalers()
+		if err != nil {
+			return err
+		} else if len(upscalers) == 0 {
+			return ErrNoUpscalers
+		}
+
+		upscaler = upscalers[0]
+	}
+
+	quality := cmdctx.ChannelSettings.OutputQuality
+	cmdctx.DoneWithSettings()
+
+	raw, err := downloadFile(imageURL)
+	if err != nil {
+		return err
+	}
+
+	upscaled, err := Upscale(cmdctx, raw, upscaler, uint(amount))
+	if err != nil || upscaled == nil {
+		return err
+	}
+
+	return ReplyImage(cmdctx, "Upscaled", fmt.Sprintf("**Upscaler:** %dx %s", amount, upscaler), "upscale", upscaled, quality)
+}
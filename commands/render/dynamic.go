@@ -0,0 +1,134 @@
+package render
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+
+	"github.com/ayunami2000/ayunsdcord/commands/command"
+	"github.com/ayunami2000/ayunsdcord/config"
+	"github.com/ayunami2000/ayunsdcord/sdapi"
+)
+
+var ErrTooManyCombinations = errors.New("too many prompt combinations")
+
+// The first outermost {a|b|c} group of the prompt, options can contain groups of their own
+func findAlternatives(prompt string) (int, int, []string) {
+	for start := 0; start < len(prompt); start++ {
+		if prompt[start] != '{' {
+			continue
+		}
+
+		depth := 0
+		last := start + 1
+		options := []string{}
+		for i := start; i < len(prompt); i++ {
+			switch prompt[i] {
+			case '{':
+				depth++
+			case '}':
+				depth--
+			case '|':
+				if depth == 1 {
+					options = append(options, prompt[last:i])
+					last = i + 1
+				}
+			}
+
+			if depth == 0 {
+				// Braces without options are left alone, but can still contain a group
+				if len(options) > 0 {
+					return start, i + 1, append(options, prompt[last:i])
+				}
+
+				break
+			}
+		}
+	}
+
+	return -1, -1, nil
+}
+
+func HasAlternatives(prompt string) bool {
+	start, _, _ := findAlternatives(prompt)
+	return start != -1
+}
+
+// Replaces every {a|b|c} in the prompt with one of its options at random
+func ExpandAlternatives(prompt string) string {
+	for {
+		start, end, options := findAlternatives(prompt)
+		if start == -1 {
+			return prompt
+		}
+
+		prompt = prompt[:start] + options[rand.Intn(len(options))] + prompt[end:]
+	}
+}
+
+func expandCombinations(prompt string, prompts []string, max int) ([]string, error) {
+	start, end, options := findAlternatives(prompt)
+	if start == -1 {
+		if len(prompts) == max {
+			return nil, ErrTooManyCombinations
+		}
+
+		return append(prompts, prompt), nil
+	}
+
+	var err error
+	for _, option := range options {
+		if prompts, err = expandCombinations(prompt[:start]+option+prompt[end:], prompts, max); err != nil {
+			return nil, err
+		}
+	}
+
+	return prompts, nil
+}
+
+// Every prompt the {a|b|c} groups can make, or an error if there are more than max
+func ExpandCombinations(prompt string, max int) ([]string, error) {
+	prompts, err := expandCombinations(prompt, []string{}, max)
+	if err != nil {
+		return nil, fmt.Errorf("%w, at most %d are allowed", err, max)
+	}
+
+	return prompts, nil
+}
+
+// Renders the same parameters once for every combination of the prompt
+func enqueueCombinations(cmdctx *command.CommandContext, data *sdapi.RenderData) error {
+	config.ConfigMutex.Lock()
+	maxCombinations := int(config.Config.MaxPromptCombinations)
+	config.ConfigMutex.Unlock()
+
+	prompts, err := ExpandCombinations(data.Prompt, maxCombinations)
+	if err != nil {
+		return err
+	}
+
+	_, err = cmdctx.TryReply("**Rendering %d prompt combinations**", len(prompts))
+	if err != nil {
+		return err
+	}
+
+	// Queued one after another so the renders come out in the order of the prompts
+	jobs := []*Job{}
+	for _, prompt := range prompts {
+		combination := data.Copy()
+		combination.Prompt = prompt
+
+		var job *Job
+		if job, err = submit(cmdctx, combination); err != nil {
+			break
+		}
+
+		jobs = append(jobs, job)
+	}
+
+	if waitErr := wait(jobs); err == nil {
+		err = waitErr
+	}
+
+	return err
+}
@@ -0,0 +1,68 @@
+package render
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestFindAlternatives(t *testing.T) {
+	tests := []struct {
+		prompt     string
+		start, end int
+		options    []string
+	}{
+		{"a cat", -1, -1, nil},
+		{"{red|blue} car", 0, 10, []string{"red", "blue"}},
+		{"a {red|blue} car", 2, 12, []string{"red", "blue"}},
+		{"{a|} b", 0, 4, []string{"a", ""}},
+		{"{a|{b|c}} d", 0, 9, []string{"a", "{b|c}"}},
+		{"{{a|b} c|d}", 0, 11, []string{"{a|b} c", "d"}},
+		{"{no options} {a|b}", 13, 18, []string{"a", "b"}},
+		{"{x {a|b}}", 3, 8, []string{"a", "b"}},
+		{"{a|b} and {c|d}", 0, 5, []string{"a", "b"}},
+		{"{a|b", -1, -1, nil},
+		{"a|b}", -1, -1, nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.prompt, func(t *testing.T) {
+			start, end, options := findAlternatives(test.prompt)
+			if start != test.start || end != test.end || !reflect.DeepEqual(options, test.options) {
+				t.Errorf("findAlternatives() = %d, %d, %q, want %d, %d, %q", start, end, options, test.start, test.end, test.options)
+			}
+		})
+	}
+}
+
+func TestExpandCombinations(t *testing.T) {
+	tests := []struct {
+		prompt string
+		max    int
+		want   []string
+		err    error
+	}{
+		{"a cat", 9, []string{"a cat"}, nil},
+		{"{red|blue} car", 9, []string{"red car", "blue car"}, nil},
+		{"{a|b} {c|d}", 9, []string{"a c", "a d", "b c", "b d"}, nil},
+		{"{a|{b|c}} d", 9, []string{"a d", "b d", "c d"}, nil},
+		{"{{a|b} x|y}", 9, []string{"a x", "b x", "y"}, nil},
+		{"{x {a|b}}", 9, []string{"{x a}", "{x b}"}, nil},
+		{"{a|b|c}", 3, []string{"a", "b", "c"}, nil},
+		{"{a|b|c|d}", 3, nil, ErrTooManyCombinations},
+		{"{a|b} {c|d}", 3, nil, ErrTooManyCombinations},
+		{"{a|b}", 0, nil, ErrTooManyCombinations},
+	}
+
+	for _, test := range tests {
+		t.Run(test.prompt, func(t *testing.T) {
+			got, err := ExpandCombinations(test.prompt, test.max)
+			if !errors.Is(err, test.err) {
+				t.Fatalf("ExpandCombinations() error = %v, want %v", err, test.err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("ExpandCombinations() = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package render
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"io"
@@ -16,23 +17,36 @@ import (
 
 var ErrFailedToGetAttachmentURL = errors.New("failed to get attachment URL")
 
-func frameEmbed(cmdctx *command.CommandContext, oldMessage *discord.Message, url string, step uint, totalSteps uint, hasInitImage bool) error {
-	settings := cmdctx.ChannelSettings
+func frameEmbed(j *Job, url string, step uint, totalSteps uint) error {
+	data := j.data
+
+	j.mutex.Lock()
+	if url != "" {
+		j.lastFrameUrl = url
+	}
+	lastFrameUrl := j.lastFrameUrl
+	finished := j.finished
+	tilePreviewUrl := j.tilePreviewUrl
+	j.mutex.Unlock()
 
 	footer := fmt.Sprintf("Step %d of %d", step, totalSteps)
-	if step >= totalSteps {
+	components := progressComponents()
+	if finished {
 		footer = "Done!"
+		components = resultComponents(data)
 	} else if step == 0 && totalSteps == 0 {
 		footer = "Error."
+		components = &discord.ContainerComponents{}
 	}
 
-	if url != "" {
-		settings.CurrentRenderInfo.LastFrameUrl = url
+	desc := ""
+	if IsTemplate(data.OriginalPrompt) {
+		desc += fmt.Sprintf("**Template:** %s\n", data.OriginalPrompt)
 	}
 
-	desc := fmt.Sprintf("**Prompt:** %s", settings.Prompt)
-	if settings.NegativePrompt != "" {
-		desc += fmt.Sprintf("\n**Negative Prompt:** %s", settings.NegativePrompt)
+	desc += fmt.Sprintf("**Prompt:** %s", data.Prompt)
+	if data.NegativePrompt != "" {
+		desc += fmt.Sprintf("\n**Negative Prompt:** %s", data.NegativePrompt)
 	}
 
 	desc += fmt.Sprintf(`
@@ -41,23 +55,47 @@ func frameEmbed(cmdctx *command.CommandContext, oldMessage *discord.Message, url
 **Inference Steps:** %d
 **Guidance Scale:** %g
 **Sampler:** %s
-**Model:** %s`, settings.Width, settings.Height, settings.InferenceSteps, settings.GuidanceScale, settings.Sampler, settings.Model)
+**Seed:** %d
+**Model:** %s`, data.Width, data.Height, data.NumInferenceSteps, data.GuidanceScale, data.SamplerName, data.Seed, data.UseStableDiffusionModel)
 
-	if settings.VAE != "" {
-		desc += fmt.Sprintf("\n**VAE:** %s", settings.VAE)
+	if data.UseVaeModel != "" {
+		desc += fmt.Sprintf("\n**VAE:** %s", data.UseVaeModel)
+	}
+	if data.UseHypernetworkModel != "" {
+		desc += fmt.Sprintf("\n**HyperNetwork:** %s", data.UseHypernetworkModel)
 	}
-	if settings.HyperNetwork != "" {
-		desc += fmt.Sprintf("\n**HyperNetwork:** %s", settings.HyperNetwork)
+	if len(data.UseLoraModel) > 0 {
+		desc += fmt.Sprintf("\n**LoRA:** %s", loraText(data))
 	}
-	if settings.Upscaler != "" {
-		desc += fmt.Sprintf("\n**Upscaler:** %dx %s", settings.UpscaleAmount, settings.Upscaler)
+	if data.UseControlnetModel != "" {
+		desc += fmt.Sprintf("\n**ControlNet:** %s (weight %g)", data.UseControlnetModel, data.ControlAlpha)
+	}
+	if data.UseUpscale != "" {
+		desc += fmt.Sprintf("\n**Upscaler:** %sx %s", data.UpscaleAmount, data.UseUpscale)
+	}
+	if data.UseFaceCorrection != "" {
+		desc += fmt.Sprintf("\n**Face Restoration:** %s", data.UseFaceCorrection)
+	}
+	if data.Tiling != "" {
+		desc += fmt.Sprintf("\n**Tiling:** %s", data.Tiling)
+		if tilePreviewUrl != "" {
+			desc += fmt.Sprintf(" ([2x2 preview](%s))", tilePreviewUrl)
+		}
 	}
 
-	if hasInitImage {
-		desc += fmt.Sprintf("\n**Img2Img Prompt Strength:** %g", settings.PromptStrength)
+	if data.Mask != "" {
+		desc += fmt.Sprintf("\n**Inpainting Prompt Strength:** %g", data.PromptStrength)
+	} else if data.InitImage != "" {
+		desc += fmt.Sprintf("\n**Img2Img Prompt Strength:** %g", data.PromptStrength)
+	}
+	if data.SubseedStrength > 0 {
+		desc += fmt.Sprintf("\n**Variation Strength:** %g", data.SubseedStrength)
+	}
+	if data.NumOutputs > 1 {
+		desc += fmt.Sprintf("\n**Batch:** %d", data.NumOutputs)
 	}
 
-	_, err := cmdctx.Executor.State.EditMessageComplex(oldMessage.ChannelID, oldMessage.ID, api.EditMessageData{
+	_, err := j.cmdctx.Executor.State.EditMessageComplex(j.message.ChannelID, j.message.ID, api.EditMessageData{
 		Content: option.NewNullableString(""),
 		Embeds: &[]discord.Embed{{
 			Title:       "Stable Diffusion",
@@ -66,56 +104,98 @@ func frameEmbed(cmdctx *command.CommandContext, oldMessage *discord.Message, url
 				Text: footer,
 			},
 			Image: &discord.EmbedImage{
-				URL: settings.CurrentRenderInfo.LastFrameUrl,
+				URL: lastFrameUrl,
 			},
 			Timestamp: discord.NewTimestamp(time.Now()),
 		}},
+		Components: components,
 	})
 
 	return err
 }
 
-func frame(cmdctx *command.CommandContext, oldMessage *discord.Message, reader io.Reader, step uint, totalSteps uint, hasInitImage bool) (*discord.Message, error) {
+// Shows a progress frame, through the frame server if there is one
+func frame(j *Job, reader io.Reader, step uint, totalSteps uint) (*discord.Message, error) {
 	if reader == nil {
-		return nil, frameEmbed(cmdctx, oldMessage, "", step, totalSteps, hasInitImage)
-	}
-
-	ext := "jpg"
-	if step == totalSteps {
-		ext = "png"
+		return nil, frameEmbed(j, "", step, totalSteps)
 	}
 
 	config.ConfigMutex.Lock()
 	frameUrl := config.Config.FrameUrl
 	config.ConfigMutex.Unlock()
 
-	if step != totalSteps && frameUrl != "" {
-		body, err := io.ReadAll(reader)
-		if err != nil {
+	body, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	if frameUrl != "" {
+		j.mutex.Lock()
+		j.frameData = body
+		j.mutex.Unlock()
+
+		return nil, frameEmbed(j, fmt.Sprintf("%s/%s/%d.jpg", frameUrl, j.message.ID, time.Now().UnixNano()), step, totalSteps)
+	}
+
+	if limit := uploadLimit(); len(body) > limit {
+		if body, err = fitImage(body, limit, j.data.OutputQuality); err != nil {
 			return nil, err
 		}
+	}
 
-		cmdctx.ChannelSettings.CurrentRenderInfo.FrameData = body
-		return nil, frameEmbed(cmdctx, oldMessage, fmt.Sprintf("%s/%s/%d.%s", frameUrl, oldMessage.ChannelID, time.Now().UnixNano(), ext), step, totalSteps, hasInitImage)
+	msg, err := dump(j, []sendpart.File{{
+		Name:   fmt.Sprintf("stable-diffusion_%d.%s", time.Now().UnixNano(), imageExtension(body)),
+		Reader: bytes.NewReader(body),
+	}})
+	if err != nil {
+		return nil, err
 	}
 
+	return msg, frameEmbed(j, msg.Attachments[0].URL, step, totalSteps)
+}
+
+// Uploads files to the image dump channel, or the channel of the render if there is none
+func dump(j *Job, files []sendpart.File) (*discord.Message, error) {
+	return dumpFiles(j.cmdctx, j.message.ChannelID, files)
+}
+
+func dumpFiles(cmdctx *command.CommandContext, channelID discord.ChannelID, files []sendpart.File) (*discord.Message, error) {
 	dumpChannel := config.GetImageDumpChannelId()
 	if dumpChannel == discord.NullChannelID {
-		dumpChannel = oldMessage.ChannelID
+		dumpChannel = channelID
 	}
 
-	msg, err := cmdctx.Executor.SendMessageComplex(dumpChannel, api.SendMessageData{
-		Files: []sendpart.File{{
-			Name:   fmt.Sprintf("stable-diffusion_%d.%s", time.Now().UnixNano(), ext),
-			Reader: reader,
-		}},
-	})
-
+	msg, err := cmdctx.Executor.SendMessageComplex(dumpChannel, api.SendMessageData{Files: files})
 	if err != nil {
 		return nil, err
-	} else if len(msg.Attachments) < 1 {
+	} else if len(msg.Attachments) < len(files) {
 		return nil, ErrFailedToGetAttachmentURL
 	}
 
-	return msg, frameEmbed(cmdctx, oldMessage, msg.Attachments[0].URL, step, totalSteps, hasInitImage)
+	return msg, nil
+}
+
+// Uploads an image that is not a render and replies with it in an embed
+func ReplyImage(cmdctx *command.CommandContext, title string, desc string, kind string, img []byte, quality uint) error {
+	fitted, err := fitUploads([][]byte{img}, quality)
+	if err != nil {
+		return err
+	}
+
+	img = fitted[0]
+	msg, err := dumpFiles(cmdctx, cmdctx.Message.ChannelID, []sendpart.File{{
+		Name:   fmt.Sprintf("stable-diffusion_%s_%d.%s", kind, time.Now().UnixNano(), imageExtension(img)),
+		Reader: bytes.NewReader(img),
+	}})
+	if err != nil {
+		return err
+	}
+
+	_, err = cmdctx.ReplyEmbed(discord.Embed{
+		Title:       title,
+		Description: desc,
+		Image:       &discord.EmbedImage{URL: msg.Attachments[0].URL},
+		Timestamp:   discord.NewTimestamp(time.Now()),
+	})
+	return err
 }
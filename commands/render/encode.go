@@ -0,0 +1,170 @@
+package render
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/jpeg"
+	"net/http"
+	"sort"
+
+	"github.com/ayunami2000/ayunsdcord/config"
+	"github.com/ayunami2000/ayunsdcord/utils"
+	xdraw "golang.org/x/image/draw"
+)
+
+var ErrUploadTooLarge = errors.New("image is too large to upload")
+
+// Smallest fraction of its size an image is downscaled to before giving up on uploading it
+const minUploadScale = 0.125
+
+func encodeJPEG(img image.Image, quality uint) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: int(quality)}); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// Converts a final image to the output format of the render, Go can only decode WebP so that is left to the backend
+func encodeOutput(raw []byte, format string, quality uint) ([]byte, error) {
+	contentType := http.DetectContentType(raw)
+	if format == "webp" && contentType != "image/webp" {
+		// The format command only allows WebP where every backend renders it, but the backends can change after that
+		format = "png"
+	}
+
+	if (format == "jpeg" && contentType == "image/jpeg") || (format == "png" && contentType == "image/png") || format == "webp" {
+		return raw, nil
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(raw))
+	if err != nil {
+		return nil, err
+	}
+
+	return encodeAs(img, format, quality)
+}
+
+// Encodes a generated image, like a grid, in the output format, falling back to PNG where Go has no encoder
+func encodeAs(img image.Image, format string, quality uint) ([]byte, error) {
+	if format == "jpeg" {
+		return encodeJPEG(img, quality)
+	}
+
+	return encodePNG(img)
+}
+
+// File extension matching the contents of an image
+func imageExtension(raw []byte) string {
+	switch http.DetectContentType(raw) {
+	case "image/jpeg":
+		return "jpg"
+	case "image/webp":
+		return "webp"
+	case "image/gif":
+		return "gif"
+	}
+
+	return "png"
+}
+
+func uploadLimit() int {
+	config.ConfigMutex.Lock()
+	defer config.ConfigMutex.Unlock()
+
+	return int(config.Config.MaxUploadSize) * 1024 * 1024
+}
+
+// Downscales a PNG until it is at most limit bytes, keeping its text chunks like the render parameters
+func fitPNG(img image.Image, texts map[string]string, limit int) ([]byte, error) {
+	keywords := []string{}
+	for keyword := range texts {
+		keywords = append(keywords, keyword)
+	}
+	sort.Strings(keywords)
+
+	size := img.Bounds().Size()
+	for scale := 0.75; scale >= minUploadScale; scale *= 0.75 {
+		encoded, err := encodePNG(resizeImage(img, uint(float64(size.X)*scale), uint(float64(size.Y)*scale), xdraw.CatmullRom))
+		if err != nil {
+			return nil, err
+		}
+
+		for _, keyword := range keywords {
+			if encoded, err = utils.SetPNGText(encoded, keyword, texts[keyword]); err != nil {
+				return nil, err
+			}
+		}
+
+		if len(encoded) <= limit {
+			return encoded, nil
+		}
+	}
+
+	return nil, ErrUploadTooLarge
+}
+
+// Shrinks an image until it is at most limit bytes, PNGs with text stay PNGs if possible and everything else becomes JPEG
+func fitImage(raw []byte, limit int, quality uint) ([]byte, error) {
+	img, _, err := image.Decode(bytes.NewReader(raw))
+	if err != nil {
+		return nil, err
+	}
+
+	if texts, err := utils.GetPNGTexts(raw); err == nil && len(texts) > 0 {
+		if fitted, err := fitPNG(img, texts, limit); err == nil {
+			return fitted, nil
+		} else if !errors.Is(err, ErrUploadTooLarge) {
+			return nil, err
+		}
+	}
+
+	size := img.Bounds().Size()
+	for scale := 1.0; scale >= minUploadScale; scale *= 0.75 {
+		scaled := img
+		if scale < 1 {
+			scaled = resizeImage(img, uint(float64(size.X)*scale), uint(float64(size.Y)*scale), xdraw.CatmullRom)
+		}
+
+		encoded, err := encodeJPEG(scaled, quality)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(encoded) <= limit {
+			return encoded, nil
+		}
+	}
+
+	return nil, ErrUploadTooLarge
+}
+
+// Shrinks the images that do not fit their share of Discord's upload limit, since they all go in one message
+func fitUploads(uploads [][]byte, quality uint) ([][]byte, error) {
+	limit := uploadLimit()
+	total := 0
+	for _, upload := range uploads {
+		total += len(upload)
+	}
+
+	if total <= limit {
+		return uploads, nil
+	}
+
+	fitted := make([][]byte, len(uploads))
+	for i, upload := range uploads {
+		fitted[i] = upload
+		if len(upload) <= limit/len(uploads) {
+			continue
+		}
+
+		var err error
+		if fitted[i], err = fitImage(upload, limit/len(uploads), quality); err != nil {
+			return nil, err
+		}
+	}
+
+	return fitted, nil
+}
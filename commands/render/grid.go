@@ -0,0 +1,144 @@
+package render
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/jpeg"
+	"image/png"
+	"math"
+	"strconv"
+
+	_ "golang.org/x/image/webp"
+
+	xdraw "golang.org/x/image/draw"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+var labelBackground = image.NewUniform(color.RGBA{0, 0, 0, 192})
+
+func decodeImages(raw [][]byte) ([]image.Image, error) {
+	images := []image.Image{}
+	for _, r := range raw {
+		img, _, err := image.Decode(bytes.NewReader(r))
+		if err != nil {
+			return nil, err
+		}
+
+		images = append(images, img)
+	}
+
+	return images, nil
+}
+
+func encodePNG(img image.Image) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// Unscaled size of the box drawLabel draws
+func labelSize(text string) image.Point {
+	face := basicfont.Face7x13
+	return image.Pt(font.MeasureString(face, text).Ceil()+6, face.Height+4)
+}
+
+// Draws white text on a dark box with its top left corner at the given point, scaled up to stay readable on large images
+func drawLabel(dst draw.Image, at image.Point, text string, scale int) {
+	face := basicfont.Face7x13
+	size := labelSize(text)
+	width, height := size.X, size.Y
+
+	label := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(label, label.Bounds(), labelBackground, image.Point{}, draw.Src)
+
+	drawer := font.Drawer{
+		Dst:  label,
+		Src:  image.White,
+		Face: face,
+		Dot:  fixed.P(3, 2+face.Ascent),
+	}
+	drawer.DrawString(text)
+
+	target := image.Rect(at.X, at.Y, at.X+width*scale, at.Y+height*scale)
+	xdraw.NearestNeighbor.Scale(dst, target, label, label.Bounds(), draw.Over, nil)
+}
+
+func labelScale(width int) int {
+	return int(math.Max(1, float64(width)/256))
+}
+
+// Lays out the images left to right, top to bottom, each labelled with its number
+func makeGrid(images []image.Image) *image.RGBA {
+	cell := images[0].Bounds().Size()
+	cols := int(math.Ceil(math.Sqrt(float64(len(images)))))
+	rows := (len(images) + cols - 1) / cols
+
+	grid := image.NewRGBA(image.Rect(0, 0, cols*cell.X, rows*cell.Y))
+	for i, img := range images {
+		at := image.Pt((i%cols)*cell.X, (i/cols)*cell.Y)
+		draw.Draw(grid, image.Rectangle{Min: at, Max: at.Add(cell)}, img, img.Bounds().Min, draw.Src)
+		drawLabel(grid, at, strconv.Itoa(i+1), labelScale(cell.X))
+	}
+
+	return grid
+}
+
+// Repeats the image twice in both directions, so seams of tiling renders show up
+func makeTilePreview(img image.Image) *image.RGBA {
+	size := img.Bounds().Size()
+	preview := image.NewRGBA(image.Rect(0, 0, size.X*2, size.Y*2))
+	for i := 0; i < 4; i++ {
+		at := image.Pt((i%2)*size.X, (i/2)*size.Y)
+		draw.Draw(preview, image.Rectangle{Min: at, Max: at.Add(size)}, img, img.Bounds().Min, draw.Src)
+	}
+
+	return preview
+}
+
+// Lays out the images in rows of len(xLabels), with the labels of each column above it and of each row left of it
+func makeXYGrid(images []image.Image, xLabels []string, yLabels []string) *image.RGBA {
+	cell := image.Point{}
+	for _, img := range images {
+		size := img.Bounds().Size()
+		cell.X = int(math.Max(float64(cell.X), float64(size.X)))
+		cell.Y = int(math.Max(float64(cell.Y), float64(size.Y)))
+	}
+
+	scale := labelScale(cell.X)
+	margin := image.Point{}
+	for _, label := range xLabels {
+		margin.Y = int(math.Max(float64(margin.Y), float64(labelSize(label).Y*scale)))
+	}
+	for _, label := range yLabels {
+		margin.X = int(math.Max(float64(margin.X), float64(labelSize(label).X*scale)))
+	}
+
+	cols := len(xLabels)
+	rows := (len(images) + cols - 1) / cols
+
+	grid := image.NewRGBA(image.Rect(0, 0, margin.X+cols*cell.X, margin.Y+rows*cell.Y))
+	draw.Draw(grid, grid.Bounds(), image.Black, image.Point{}, draw.Src)
+
+	for i, label := range xLabels {
+		drawLabel(grid, image.Pt(margin.X+i*cell.X, 0), label, scale)
+	}
+	for i, label := range yLabels {
+		drawLabel(grid, image.Pt(0, margin.Y+i*cell.Y), label, scale)
+	}
+
+	for i, img := range images {
+		size := img.Bounds().Size()
+		// Centered, in case the images differ in size
+		at := image.Pt(margin.X+(i%cols)*cell.X+(cell.X-size.X)/2, margin.Y+(i/cols)*cell.Y+(cell.Y-size.Y)/2)
+		draw.Draw(grid, image.Rectangle{Min: at, Max: at.Add(size)}, img, img.Bounds().Min, draw.Src)
+	}
+
+	return grid
+}
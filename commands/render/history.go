@@ -0,0 +1,128 @@
+package render
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/ayunami2000/ayunsdcord/sdapi"
+	"github.com/ayunami2000/ayunsdcord/store"
+	"github.com/diamondburned/arikawa/v3/discord"
+)
+
+const historyBucket = "history"
+
+const (
+	OutcomeSucceeded = "succeeded"
+	OutcomeFailed    = "failed"
+	OutcomeStopped   = "stopped"
+)
+
+// Every render that was started, whether it finished or not
+type HistoryEntry struct {
+	RequestedBy discord.UserID
+	GuildID     discord.GuildID
+	ChannelID   discord.ChannelID
+	MessageID   discord.MessageID
+	Data        sdapi.RenderData
+	Backend     string
+	Started     time.Time
+	Duration    time.Duration
+	ImageURLs   []string
+	Upload      Upload
+	Outcome     string
+	Error       string `json:",omitempty"`
+}
+
+// Link to the message of the render
+func (e *HistoryEntry) URL() string {
+	return discord.Message{GuildID: e.GuildID, ChannelID: e.ChannelID, ID: e.MessageID}.URL()
+}
+
+// Links to the images that still work, for as long as the message they were uploaded in exists
+func (e *HistoryEntry) Images() []string {
+	return e.Upload.links(e.ImageURLs, imagesOffset(e.ImageURLs))
+}
+
+// Zero padded, so the keys sort by message ID and with that by time
+func historyKey(id discord.MessageID) string {
+	return fmt.Sprintf("%020d", uint64(id))
+}
+
+func (j *Job) saveHistory(backend string, started time.Time) {
+	j.mutex.Lock()
+	entry := &HistoryEntry{
+		RequestedBy: j.RequestedBy,
+		GuildID:     j.cmdctx.Message.GuildID,
+		ChannelID:   j.message.ChannelID,
+		MessageID:   j.message.ID,
+		Data:        *j.data,
+		Backend:     backend,
+		Started:     started,
+		Duration:    time.Since(started),
+		ImageURLs:   j.imageURLs,
+		Upload:      j.upload,
+		Outcome:     OutcomeFailed,
+	}
+
+	if j.finished {
+		entry.Outcome = OutcomeSucceeded
+	} else if j.stopped {
+		entry.Outcome = OutcomeStopped
+	}
+	j.mutex.Unlock()
+
+	// The images the render started from are kept with its record, the history only needs the parameters
+	entry.Data.InitImage = ""
+	entry.Data.Mask = ""
+	entry.Data.ControlImage = ""
+	entry.Data.VariationImage = ""
+
+	if j.err != nil {
+		entry.Error = j.err.Error()
+	}
+
+	if err := store.Put(historyBucket, historyKey(entry.MessageID), entry); err != nil {
+		log.Println("Could not save render history:", err)
+	}
+}
+
+// The latest renders of a user, newest first, only those in the guild unless everywhere is set
+func History(userID discord.UserID, guildID discord.GuildID, limit int, everywhere bool) ([]HistoryEntry, error) {
+	return QueryHistory(func(entry *HistoryEntry) bool {
+		return entry.RequestedBy == userID && (everywhere || (guildID.IsValid() && entry.GuildID == guildID))
+	}, 0, limit)
+}
+
+// Renders the filter matches, newest first, leaving out the first offset of them
+func QueryHistory(match func(entry *HistoryEntry) bool, offset int, limit int) ([]HistoryEntry, error) {
+	entries := []HistoryEntry{}
+	skipped := 0
+	err := store.Reverse(historyBucket, func(key string, entry *HistoryEntry) bool {
+		if !match(entry) {
+			return true
+		}
+
+		if skipped < offset {
+			skipped++
+			return true
+		}
+
+		entries = append(entries, *entry)
+		return len(entries) < limit
+	})
+
+	return entries, err
+}
+
+func GetHistoryEntry(id discord.MessageID) (*HistoryEntry, error) {
+	entry := &HistoryEntry{}
+	exists, err := store.Get(historyBucket, historyKey(id), entry)
+	if err != nil {
+		return nil, err
+	} else if !exists {
+		return nil, ErrRecordNotFound
+	}
+
+	return entry, nil
+}
@@ -1,38 +1,175 @@
 package render
 
 import (
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
 	"errors"
+	"fmt"
+	"image"
 	"io"
 	"math"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/ayunami2000/ayunsdcord/commands/command"
 	"github.com/ayunami2000/ayunsdcord/config"
 	"github.com/ayunami2000/ayunsdcord/sdapi"
+	"github.com/ayunami2000/ayunsdcord/store"
 	"github.com/diamondburned/arikawa/v3/discord"
+	xdraw "golang.org/x/image/draw"
 )
 
 var ErrChangingImg2ImgNotAllowed = errors.New("changing the Img2Img image is disabled")
+var ErrDownloadFailed = errors.New("could not download the image")
+var ErrControlImageNotFound = errors.New("the control image is gone, please set it again")
 
-func img2img(cmdctx *command.CommandContext, attachment discord.Attachment, data *sdapi.RenderData) error {
-	res, err := http.Get(attachment.URL)
+const controlImagesBucket = "controlimages"
+
+// Fails on error pages, like those of expired attachment links, instead of passing them on as the image
+func download(url string) (*http.Response, error) {
+	res, err := http.Get(url)
+	if err != nil {
+		return nil, err
+	}
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		res.Body.Close()
+		return nil, fmt.Errorf("%w: %s", ErrDownloadFailed, res.Status)
+	}
+
+	return res, nil
+}
+
+// Downloads a file as it is
+func downloadFile(url string) ([]byte, error) {
+	res, err := download(url)
+	if err != nil {
+		return nil, err
+	}
+
+	defer res.Body.Close()
+	return io.ReadAll(res.Body)
+}
+
+// Downloads and decodes a PNG, JPEG or WebP image
+func DownloadImage(url string) (image.Image, error) {
+	res, err := download(url)
+	if err != nil {
+		return nil, err
+	}
+
+	defer res.Body.Close()
+	img, _, err := image.Decode(res.Body)
+	return img, err
+}
+
+// Stretches the image to the size of the render, backends expect both to match
+func resizeImage(img image.Image, width uint, height uint, scaler xdraw.Scaler) image.Image {
+	if img.Bounds().Dx() == int(width) && img.Bounds().Dy() == int(height) {
+		return img
+	}
+
+	resized := image.NewRGBA(image.Rect(0, 0, int(width), int(height)))
+	scaler.Scale(resized, resized.Bounds(), img, img.Bounds(), xdraw.Src, nil)
+	return resized
+}
+
+func pngDataURL(img image.Image) (string, error) {
+	encoded, err := encodePNG(img)
+	if err != nil {
+		return "", err
+	}
+
+	return "data:image/png;base64," + base64.StdEncoding.EncodeToString(encoded), nil
+}
+
+func setInitImage(img image.Image, data *sdapi.RenderData) (err error) {
+	data.InitImage, err = pngDataURL(resizeImage(img, data.Width, data.Height, xdraw.CatmullRom))
+	return err
+}
+
+// Downloads an image and uses it as the Img2Img image of the render
+func loadInitImage(url string, data *sdapi.RenderData) error {
+	img, err := DownloadImage(url)
 	if err != nil {
 		return err
 	}
 
-	img, err := io.ReadAll(res.Body)
+	return setInitImage(img, data)
+}
+
+// Downloads an image to guide ControlNet renders, no larger than the render, and stores it under the returned key
+func LoadControlImage(url string, width uint, height uint) (string, error) {
+	img, err := DownloadImage(url)
+	if err != nil {
+		return "", err
+	}
+
+	// The backend crops it to the render size itself, so only shrink it and keep the aspect ratio
+	scale := math.Min(float64(width)/float64(img.Bounds().Dx()), float64(height)/float64(img.Bounds().Dy()))
+	if scale < 1 {
+		img = resizeImage(img, uint(math.Max(math.Round(float64(img.Bounds().Dx())*scale), 1)), uint(math.Max(math.Round(float64(img.Bounds().Dy())*scale), 1)), xdraw.CatmullRom)
+	}
+
+	dataURL, err := pngDataURL(img)
+	if err != nil {
+		return "", err
+	}
+
+	// Keyed by the contents, so renders and channels using the same image share it
+	sum := sha256.Sum256([]byte(dataURL))
+	key := hex.EncodeToString(sum[:])
+	return key, store.Put(controlImagesBucket, key, dataURL)
+}
+
+// Swaps the key of the control image for the image itself and returns the key, older settings and records have the data URL already
+func resolveControlImage(data *sdapi.RenderData) (string, error) {
+	key := data.ControlImage
+	if key == "" || strings.HasPrefix(key, "data:") {
+		return "", nil
+	}
+
+	exists, err := store.Get(controlImagesBucket, key, &data.ControlImage)
+	if err != nil {
+		return "", err
+	} else if !exists {
+		return "", ErrControlImageNotFound
+	}
+
+	return key, nil
+}
+
+// Uses the first image attachment as the Img2Img image, and a second one, or else the transparent parts of the first, as the inpainting mask
+func img2img(cmdctx *command.CommandContext, attachments []discord.Attachment, data *sdapi.RenderData) error {
+	img, err := DownloadImage(attachments[0].URL)
 	if err != nil {
 		return err
 	}
 
-	defer res.Body.Close()
-	data.InitImage = "data:" + attachment.ContentType + ";base64," + base64.StdEncoding.EncodeToString(img)
+	if err := setInitImage(img, data); err != nil {
+		return err
+	}
 
-	_, _ = cmdctx.TryReply("**Loaded Img2Img image from attachment!**")
+	mask := maskFromAlpha(img)
+	if len(attachments) > 1 && strings.HasPrefix(attachments[1].ContentType, "image/") {
+		if mask, err = DownloadImage(attachments[1].URL); err != nil {
+			return err
+		}
+	}
+
+	if mask != nil {
+		if err := setMask(mask, data); err != nil {
+			return err
+		}
+
+		_, _ = cmdctx.TryReply("**Loaded Img2Img image and inpainting mask from attachment!**")
+	} else {
+		_, _ = cmdctx.TryReply("**Loaded Img2Img image from attachment!**")
+	}
 
-	if attachment.Description != "" && config.CanChange(cmdctx.ChannelSettings.InUse, "promptstrength") == nil {
+	if attachments[0].Description != "" && config.CanChange("promptstrength") == nil {
 		f, err := strconv.ParseFloat(cmdctx.Args, 64)
 		if err != nil {
 			return err
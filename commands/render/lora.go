@@ -0,0 +1,80 @@
+package render
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/ayunami2000/ayunsdcord/sdapi"
+)
+
+var ErrInvalidLora = errors.New("invalid LoRA")
+var ErrInvalidLoraWeight = errors.New("invalid LoRA weight")
+
+var loraRegex = regexp.MustCompile(`<lora:([^:>]+)(?::([^>]*))?>`)
+
+// The name of the LoRA as the backend knows it
+func FindLora(name string) (string, error) {
+	res, err := sdapi.GetModels()
+	if err != nil {
+		return "", err
+	}
+
+	for _, l := range res.Options.Lora {
+		if strings.EqualFold(l, strings.TrimSpace(name)) {
+			return l, nil
+		}
+	}
+
+	return "", fmt.Errorf("%w: %s", ErrInvalidLora, name)
+}
+
+// Adds a LoRA to the render, or changes its weight if it is already used
+func addLora(data *sdapi.RenderData, name string, weight float64) {
+	for i, l := range data.UseLoraModel {
+		if l == name {
+			data.LoraAlpha[i] = weight
+			return
+		}
+	}
+
+	data.UseLoraModel = append(data.UseLoraModel, name)
+	data.LoraAlpha = append(data.LoraAlpha, weight)
+}
+
+// Moves <lora:name:weight> tags out of the prompt and into the LoRAs of the render
+func extractLoras(data *sdapi.RenderData) error {
+	matches := loraRegex.FindAllStringSubmatch(data.Prompt, -1)
+	for _, match := range matches {
+		name, err := FindLora(match[1])
+		if err != nil {
+			return err
+		}
+
+		weight := 1.0
+		if match[2] != "" {
+			if weight, err = strconv.ParseFloat(match[2], 64); err != nil {
+				return ErrInvalidLoraWeight
+			}
+		}
+
+		addLora(data, name, weight)
+	}
+
+	if len(matches) > 0 {
+		data.Prompt = strings.Join(strings.Fields(loraRegex.ReplaceAllString(data.Prompt, "")), " ")
+	}
+
+	return nil
+}
+
+func loraText(data *sdapi.RenderData) string {
+	loras := []string{}
+	for i, l := range data.UseLoraModel {
+		loras = append(loras, fmt.Sprintf("%s (%g)", l, data.LoraAlpha[i]))
+	}
+
+	return strings.Join(loras, ", ")
+}
@@ -0,0 +1,68 @@
+package render
+
+import (
+	"errors"
+	"image"
+	"image/color"
+
+	"github.com/ayunami2000/ayunsdcord/sdapi"
+	xdraw "golang.org/x/image/draw"
+)
+
+var ErrEmptyMask = errors.New("the inpainting mask does not cover anything")
+var ErrFullMask = errors.New("the inpainting mask covers the whole image, use a normal render instead")
+
+// White where the image is transparent, or nil if it is fully opaque
+func maskFromAlpha(img image.Image) image.Image {
+	bounds := img.Bounds()
+	mask := image.NewGray(bounds)
+	transparent := false
+
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			_, _, _, a := img.At(x, y).RGBA()
+			if a < 0xffff {
+				transparent = true
+			}
+
+			mask.SetGray(x, y, color.Gray{Y: 255 - uint8(a>>8)})
+		}
+	}
+
+	if !transparent {
+		return nil
+	}
+
+	return mask
+}
+
+// Converts the mask to black and white at the size of the render, white being the part to repaint
+func setMask(img image.Image, data *sdapi.RenderData) error {
+	resized := resizeImage(img, data.Width, data.Height, xdraw.ApproxBiLinear)
+	bounds := resized.Bounds()
+	mask := image.NewGray(bounds)
+	covered := 0
+
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			if color.GrayModel.Convert(resized.At(x, y)).(color.Gray).Y >= 128 {
+				mask.SetGray(x, y, color.Gray{Y: 255})
+				covered++
+			}
+		}
+	}
+
+	if covered == 0 {
+		return ErrEmptyMask
+	} else if covered == bounds.Dx()*bounds.Dy() {
+		return ErrFullMask
+	}
+
+	dataURL, err := pngDataURL(mask)
+	if err != nil {
+		return err
+	}
+
+	data.Mask = dataURL
+	return nil
+}
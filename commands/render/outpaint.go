@@ -0,0 +1,88 @@
+package render
+
+import (
+	"image"
+	"image/color"
+
+	"github.com/ayunami2000/ayunsdcord/commands/command"
+	"github.com/ayunami2000/ayunsdcord/config"
+	"github.com/ayunami2000/ayunsdcord/sdapi"
+)
+
+// Pixels of the original image that are repainted along each extended side, so the seam blends in
+const outpaintOverlap = 16
+
+// The new space starts out as stretched edges, so it needs to be mostly repainted
+const outpaintStrength = 0.9
+
+// Space to add on each side of an image
+type Padding struct {
+	Left, Top, Right, Bottom int
+}
+
+func clamp(i int, min int, max int) int {
+	if i < min {
+		return min
+	} else if i > max {
+		return max
+	}
+
+	return i
+}
+
+// Places the image on a larger canvas, with the edges stretched out into the new space, and renders the new space as an inpaint
+func Outpaint(cmdctx *command.CommandContext, img image.Image, data *sdapi.RenderData, padding Padding) error {
+	if err := config.CanChange("img2img"); err != nil {
+		return err
+	}
+
+	bounds := img.Bounds()
+	canvas := image.NewRGBA(image.Rect(0, 0, bounds.Dx()+padding.Left+padding.Right, bounds.Dy()+padding.Top+padding.Bottom))
+	mask := image.NewGray(canvas.Bounds())
+
+	kept := image.Rect(padding.Left, padding.Top, padding.Left+bounds.Dx(), padding.Top+bounds.Dy())
+	if padding.Left > 0 {
+		kept.Min.X += outpaintOverlap
+	}
+	if padding.Top > 0 {
+		kept.Min.Y += outpaintOverlap
+	}
+	if padding.Right > 0 {
+		kept.Max.X -= outpaintOverlap
+	}
+	if padding.Bottom > 0 {
+		kept.Max.Y -= outpaintOverlap
+	}
+
+	for y := 0; y < canvas.Bounds().Dy(); y++ {
+		for x := 0; x < canvas.Bounds().Dx(); x++ {
+			sx := bounds.Min.X + clamp(x-padding.Left, 0, bounds.Dx()-1)
+			sy := bounds.Min.Y + clamp(y-padding.Top, 0, bounds.Dy()-1)
+			canvas.Set(x, y, img.At(sx, sy))
+
+			if !image.Pt(x, y).In(kept) {
+				mask.SetGray(x, y, color.Gray{Y: 255})
+			}
+		}
+	}
+
+	data.Width = uint(canvas.Bounds().Dx())
+	data.Height = uint(canvas.Bounds().Dy())
+
+	if err := setInitImage(canvas, data); err != nil {
+		return err
+	}
+
+	if err := setMask(mask, data); err != nil {
+		return err
+	}
+
+	data.PromptStrength = outpaintStrength
+
+	_, err := cmdctx.TryReply("**Outpainting to:** %dx%d", data.Width, data.Height)
+	if err != nil {
+		return err
+	}
+
+	return Enqueue(cmdctx, data)
+}
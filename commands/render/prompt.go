@@ -0,0 +1,21 @@
+package render
+
+import "github.com/ayunami2000/ayunsdcord/sdapi"
+
+// Whether the prompt as written differs from what gets rendered
+func IsTemplate(prompt string) bool {
+	return HasWildcards(prompt) || HasAlternatives(prompt)
+}
+
+// Resolves the prompt as written into the one that is rendered
+func preparePrompt(data *sdapi.RenderData) error {
+	prompt, err := ExpandWildcards(data.Prompt)
+	if err != nil {
+		return err
+	}
+
+	data.Prompt = ExpandAlternatives(prompt)
+
+	// Wildcards can contain LoRAs too
+	return extractLoras(data)
+}
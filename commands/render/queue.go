@@ -0,0 +1,292 @@
+package render
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ayunami2000/ayunsdcord/commands/command"
+	"github.com/ayunami2000/ayunsdcord/sdapi"
+	"github.com/diamondburned/arikawa/v3/discord"
+)
+
+var ErrJobFinished = errors.New("render already finished")
+
+// Shortest time between updates of the queue positions, every update edits the message of each queued job
+const positionUpdateInterval = 2 * time.Second
+
+// Every render goes through one FIFO queue, jobs are started whenever a backend has a free slot
+type scheduler struct {
+	mutex sync.Mutex
+	queue []*Job
+	// Queued and running jobs by their message, the cells of an X/Y plot share one
+	jobs map[discord.MessageID][]*Job
+	wake chan struct{}
+	// Signals that positions changed, several changes in a row lead to a single update
+	reposition chan struct{}
+}
+
+var queue *scheduler
+
+func init() {
+	queue = newScheduler()
+}
+
+func newScheduler() *scheduler {
+	s := &scheduler{
+		jobs:       make(map[discord.MessageID][]*Job),
+		wake:       make(chan struct{}, 1),
+		reposition: make(chan struct{}, 1),
+	}
+
+	go s.run()
+	go s.updatePositions()
+	return s
+}
+
+func (s *scheduler) run() {
+	for {
+		s.mutex.Lock()
+		empty := len(s.queue) == 0
+		s.mutex.Unlock()
+
+		if empty {
+			<-s.wake
+			continue
+		}
+
+		slot := sdapi.Acquire()
+
+		s.mutex.Lock()
+		if len(s.queue) == 0 {
+			s.mutex.Unlock()
+			slot.Release()
+			continue
+		}
+
+		job := s.queue[0]
+		s.queue = s.queue[1:]
+		job.mutex.Lock()
+		job.running = true
+		job.mutex.Unlock()
+		s.mutex.Unlock()
+
+		s.positionsChanged()
+		go job.start(slot)
+	}
+}
+
+func (s *scheduler) push(job *Job) {
+	s.mutex.Lock()
+	s.queue = append(s.queue, job)
+	s.jobs[job.message.ID] = append(s.jobs[job.message.ID], job)
+	s.mutex.Unlock()
+
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (s *scheduler) length() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return len(s.queue)
+}
+
+// Removes a job that has not been started yet, returns false if it already is running
+func (s *scheduler) remove(job *Job) bool {
+	s.mutex.Lock()
+	removed := false
+	for i, j := range s.queue {
+		if j == job {
+			s.queue = append(s.queue[:i], s.queue[i+1:]...)
+			s.forget(job)
+			removed = true
+			break
+		}
+	}
+	s.mutex.Unlock()
+
+	if removed {
+		s.positionsChanged()
+	}
+
+	return removed
+}
+
+func (s *scheduler) finish(job *Job) {
+	s.mutex.Lock()
+	s.forget(job)
+	s.mutex.Unlock()
+}
+
+// Must be called with the mutex held
+func (s *scheduler) forget(job *Job) {
+	jobs := s.jobs[job.message.ID]
+	for i, j := range jobs {
+		if j == job {
+			jobs = append(jobs[:i:i], jobs[i+1:]...)
+			break
+		}
+	}
+
+	if len(jobs) == 0 {
+		delete(s.jobs, job.message.ID)
+	} else {
+		s.jobs[job.message.ID] = jobs
+	}
+}
+
+func (s *scheduler) positionsChanged() {
+	select {
+	case s.reposition <- struct{}{}:
+	default:
+	}
+}
+
+func (s *scheduler) updatePositions() {
+	for range s.reposition {
+		s.mutex.Lock()
+		queued := append([]*Job{}, s.queue...)
+		// Only the first job of a message shows its position, later cells of a plot wait for the earlier ones anyway
+		shown := map[*Job]bool{}
+		for _, job := range queued {
+			shown[job] = s.jobs[job.message.ID][0] == job
+		}
+		s.mutex.Unlock()
+
+		for i, job := range queued {
+			job.mutex.Lock()
+			changed := job.position != i+1
+			job.position = i + 1
+			job.mutex.Unlock()
+
+			if changed && shown[job] {
+				_, _ = job.cmdctx.Executor.EditMessage(job.message.ChannelID, job.message.ID, positionText(i+1))
+			}
+		}
+
+		// Changes made in the meantime are picked up together by the next pass
+		time.Sleep(positionUpdateInterval)
+	}
+}
+
+func positionText(position int) string {
+	if position == 0 {
+		return "**Loading...**"
+	}
+
+	return fmt.Sprintf("**You are #%d in queue**", position)
+}
+
+// Replies with the position the job gets in the queue, 0 if a backend is free to start it right away
+func replyQueued(cmdctx *command.CommandContext) (*discord.Message, int, error) {
+	position := queue.length() + 1
+	if position == 1 && sdapi.HasFreeSlot() {
+		position = 0
+	}
+
+	msg, err := cmdctx.TryReply(positionText(position))
+	return msg, position, err
+}
+
+// Waits for renders queued with submit, returning the first error
+func wait(jobs []*Job) error {
+	var err error
+	for _, job := range jobs {
+		<-job.done
+		if err == nil {
+			err = job.err
+		}
+	}
+
+	return err
+}
+
+// Queues a render and waits for it to finish
+func Enqueue(cmdctx *command.CommandContext, data *sdapi.RenderData) error {
+	_, err := enqueue(cmdctx, data)
+	return err
+}
+
+func enqueue(cmdctx *command.CommandContext, data *sdapi.RenderData) (*Job, error) {
+	job, err := submit(cmdctx, data)
+	if err != nil {
+		return nil, err
+	}
+
+	<-job.done
+	return job, job.err
+}
+
+// Queues a render without waiting for it, so several can be queued in order
+func submit(cmdctx *command.CommandContext, data *sdapi.RenderData) (*Job, error) {
+	if err := preparePrompt(data); err != nil {
+		return nil, err
+	}
+
+	msg, position, err := replyQueued(cmdctx)
+	if err != nil {
+		return nil, err
+	}
+
+	job := &Job{
+		cmdctx:      cmdctx,
+		data:        data,
+		message:     msg,
+		position:    position,
+		RequestedBy: cmdctx.Message.Author.ID,
+		done:        make(chan struct{}),
+	}
+
+	// The settings are not touched after this, so other commands of the channel don't have to wait for the render
+	cmdctx.DoneWithSettings()
+
+	queue.push(job)
+	return job, nil
+}
+
+// The running or next job of a message
+func GetJob(id discord.MessageID) *Job {
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+
+	if jobs := queue.jobs[id]; len(jobs) > 0 {
+		return jobs[0]
+	}
+
+	return nil
+}
+
+// Every queued and running job of a message, in queue order
+func MessageJobs(id discord.MessageID) []*Job {
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+
+	return append([]*Job{}, queue.jobs[id]...)
+}
+
+// Queued and running jobs in a channel, oldest first
+func ChannelJobs(id discord.ChannelID) []*Job {
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+
+	jobs := []*Job{}
+	for _, messageJobs := range queue.jobs {
+		for _, job := range messageJobs {
+			if job.message.ChannelID == id {
+				jobs = append(jobs, job)
+			}
+		}
+	}
+
+	sort.SliceStable(jobs, func(i, j int) bool {
+		return jobs[i].message.ID < jobs[j].message.ID
+	})
+
+	return jobs
+}
@@ -0,0 +1,97 @@
+package render
+
+import (
+	"errors"
+
+	"github.com/ayunami2000/ayunsdcord/config"
+	"github.com/ayunami2000/ayunsdcord/sdapi"
+	"github.com/ayunami2000/ayunsdcord/store"
+	"github.com/diamondburned/arikawa/v3/discord"
+)
+
+var ErrRecordNotFound = errors.New("no render found for that message")
+var ErrInvalidImageIndex = errors.New("invalid image number")
+var ErrRecordElsewhere = errors.New("that render is from another server")
+
+const recordsBucket = "renders"
+
+// Message ID of the last finished render of each channel
+const lastRendersBucket = "lastrenders"
+
+// What is kept of a finished render, keyed by the ID of its message, so it can be rendered again
+type Record struct {
+	RequestedBy discord.UserID
+	ChannelID   discord.ChannelID
+	// Unset for renders in DMs and for those recorded before it was kept
+	GuildID discord.GuildID
+	Data    sdapi.RenderData
+	// The grid for batches, otherwise the image itself
+	ImageURL  string
+	ImageURLs []string
+	// Unset for renders recorded before it was kept, their links can't be refreshed
+	Upload Upload
+}
+
+func GetRecord(id discord.MessageID) (*Record, error) {
+	record := &Record{}
+	exists, err := store.Get(recordsBucket, id.String(), record)
+	if err != nil {
+		return nil, err
+	} else if !exists {
+		return nil, ErrRecordNotFound
+	}
+
+	return record, nil
+}
+
+func LastRecord(channelID discord.ChannelID) (*Record, error) {
+	var id discord.MessageID
+	exists, err := store.Get(lastRendersBucket, channelID.String(), &id)
+	if err != nil {
+		return nil, err
+	} else if !exists {
+		return nil, ErrRecordNotFound
+	}
+
+	return GetRecord(id)
+}
+
+// Whether the render may be shown in reply to the message, which is only the case within the server or DM it is from
+func (r *Record) VisibleFrom(msg *discord.Message) bool {
+	if r.ChannelID == msg.ChannelID {
+		return true
+	}
+
+	return r.GuildID.IsValid() && r.GuildID == msg.GuildID
+}
+
+// URL of one image of the render, counting from 0
+func (r *Record) Image(index int) (string, error) {
+	if len(r.ImageURLs) == 0 && index == 0 {
+		return r.ImageURL, nil
+	} else if index < 0 || index >= len(r.ImageURLs) {
+		return "", ErrInvalidImageIndex
+	}
+
+	return r.Upload.links(r.ImageURLs, imagesOffset(r.ImageURLs))[index], nil
+}
+
+// A copy of the recorded parameters, with the progress streaming of the current config
+func (r *Record) RenderData() *sdapi.RenderData {
+	data := r.Data
+
+	config.ConfigMutex.Lock()
+	data.StreamImageProgressInterval = config.Config.StreamImageProgress
+	config.ConfigMutex.Unlock()
+
+	data.StreamImageProgress = data.StreamImageProgressInterval > 0
+	return &data
+}
+
+func saveRecord(id discord.MessageID, record *Record) error {
+	if err := store.Put(recordsBucket, id.String(), record); err != nil {
+		return err
+	}
+
+	return store.Put(lastRendersBucket, record.ChannelID.String(), id)
+}
@@ -8,68 +8,239 @@ import (
 	"math/rand"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/ayunami2000/ayunsdcord/commands/command"
 	"github.com/ayunami2000/ayunsdcord/config"
 	"github.com/ayunami2000/ayunsdcord/sdapi"
 	"github.com/ayunami2000/ayunsdcord/utils"
+	"github.com/diamondburned/arikawa/v3/api"
 	"github.com/diamondburned/arikawa/v3/discord"
 )
 
-var ErrAlreadyInProgress = errors.New("render already in progress")
-var RenderCommand = command.NewCommand("render", []string{"randomrender", "rr", "r"}, Run)
+var RenderCommand = command.NewCommand("render", []string{"randomrender", "rr", "r"}, Run).Describe("Renders an image",
+	command.Option{Name: "prompt", Description: "What to render, defaults to the current prompt", Type: command.StringOption},
+	command.Option{Name: "image", Description: "Image to use for Img2Img", Type: command.AttachmentOption})
+
+type Job struct {
+	RequestedBy discord.UserID
+
+	cmdctx   *command.CommandContext
+	data     *sdapi.RenderData
+	message  *discord.Message
+	position int
+	done     chan struct{}
+	err      error
+	// Cells of an X/Y plot only hand their images to the plot, which is posted instead
+	plotCell bool
+	// Runs instead of a render for jobs that use the backend otherwise, like upscales
+	work func(slot *sdapi.Slot) error
+
+	mutex        sync.Mutex
+	running      bool
+	stopped      bool
+	finished     bool
+	task         *sdapi.Task
+	lastFrameUrl string
+	frameData    []byte
+	// Final images, once the render finished
+	images    [][]byte
+	imageURLs []string
+	upload    Upload
+	// Key of the stored control image, which the record keeps instead of the image
+	controlImage string
+	// 2x2 preview of the first image, for tiling renders
+	tilePreviewUrl string
+}
 
-func Run(cmdctx *command.CommandContext) error {
-	if !cmdctx.ChannelSettings.InUse.CompareAndSwap(false, true) {
-		return ErrAlreadyInProgress
+func (j *Job) FrameData() []byte {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	return j.frameData
+}
+
+// Removes the job from the queue, or stops it on the backend if it already started, the cells of an X/Y plot are stopped together
+func (j *Job) Stop() error {
+	if !j.plotCell {
+		if j.dequeue() {
+			_, _ = j.cmdctx.Executor.EditMessage(j.message.ChannelID, j.message.ID, "**Removed from queue.**")
+			return nil
+		}
+
+		return j.stopRunning()
 	}
 
-	defer cmdctx.ChannelSettings.InUse.Store(false)
+	removed, running := false, false
+	for _, job := range MessageJobs(j.message.ID) {
+		if job.dequeue() {
+			removed = true
+		} else if err := job.stopRunning(); err == nil {
+			running = true
+		} else if !errors.Is(err, ErrJobFinished) {
+			return err
+		}
+	}
 
-	if cmdctx.Args != "" && config.CanChange_NoLock("prompt") {
-		cmdctx.ChannelSettings.Prompt = utils.TruncateText(cmdctx.Args, 512)
+	if !removed && !running {
+		return ErrJobFinished
+	}
+
+	// A running cell keeps showing its progress until it stopped
+	if !running {
+		_, _ = j.cmdctx.Executor.EditMessage(j.message.ChannelID, j.message.ID, "**Removed from queue.**")
+	}
+
+	return nil
+}
+
+// Takes the job out of the queue if it did not start yet
+func (j *Job) dequeue() bool {
+	if !queue.remove(j) {
+		return false
+	}
+
+	j.mutex.Lock()
+	j.stopped = true
+	j.mutex.Unlock()
+
+	close(j.done)
+	return true
+}
+
+func (j *Job) stopRunning() error {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	if !j.running {
+		return ErrJobFinished
+	}
+
+	j.stopped = true
+	if j.task == nil {
+		return nil
+	}
+
+	return j.task.Stop()
+}
+
+func (j *Job) start(slot *sdapi.Slot) {
+	started := time.Now()
+	if j.work != nil {
+		j.err = j.work(slot)
+		slot.Release()
+	} else {
+		j.err = j.render(slot)
+		j.saveHistory(slot.Backend(), started)
+	}
+
+	j.mutex.Lock()
+	j.running = false
+	finished := j.finished
+	j.mutex.Unlock()
+
+	// Don't leave the stop button behind on renders that never finished
+	if !finished {
+		_, _ = j.cmdctx.Executor.EditMessageComplex(j.message.ChannelID, j.message.ID, api.EditMessageData{
+			Components: &discord.ContainerComponents{},
+		})
+	}
+
+	queue.finish(j)
+	close(j.done)
+}
+
+// Render parameters from the channel settings, with a random seed unless one is set
+func NewRenderData(settings *command.ChannelSettings) *sdapi.RenderData {
+	config.ConfigMutex.Lock()
+	streamImageProgress := config.Config.StreamImageProgress
+	config.ConfigMutex.Unlock()
+
+	seed := settings.Seed
+	if seed < 0 {
+		seed = int(rand.Int31())
 	}
 
 	data := &sdapi.RenderData{
-		Prompt:                      cmdctx.ChannelSettings.Prompt,
-		Seed:                        int(rand.Int31()),
-		NegativePrompt:              cmdctx.ChannelSettings.NegativePrompt,
-		NumOutputs:                  1,
-		NumInferenceSteps:           cmdctx.ChannelSettings.InferenceSteps,
-		GuidanceScale:               cmdctx.ChannelSettings.GuidanceScale,
-		Width:                       cmdctx.ChannelSettings.Width,
-		Height:                      cmdctx.ChannelSettings.Height,
+		Prompt:                      settings.Prompt,
+		Seed:                        seed,
+		NegativePrompt:              settings.NegativePrompt,
+		NumOutputs:                  settings.BatchSize,
+		NumInferenceSteps:           settings.InferenceSteps,
+		GuidanceScale:               settings.GuidanceScale,
+		Width:                       settings.Width,
+		Height:                      settings.Height,
 		VramUsageLevel:              "high",
-		UseStableDiffusionModel:     cmdctx.ChannelSettings.Model,
+		UseStableDiffusionModel:     settings.Model,
 		StreamProgressUpdates:       true,
-		StreamImageProgress:         config.Config.StreamImageProgress > 0,
-		StreamImageProgressInterval: config.Config.StreamImageProgress,
+		StreamImageProgress:         streamImageProgress > 0,
+		StreamImageProgressInterval: streamImageProgress,
 		ShowOnlyFilteredImage:       true,
-		OutputFormat:                "png",
-		OutputQuality:               75,
+		OutputFormat:                settings.OutputFormat,
+		OutputQuality:               settings.OutputQuality,
 		MetadataOutputFormat:        "txt",
-		OriginalPrompt:              cmdctx.ChannelSettings.Prompt,
+		OriginalPrompt:              settings.Prompt,
 		ActiveTags:                  []string{},
 		InactiveTags:                []string{},
-		SamplerName:                 cmdctx.ChannelSettings.Sampler,
-		SessionId:                   cmdctx.ChannelSettings.SessionID,
-		UseVaeModel:                 cmdctx.ChannelSettings.VAE,
-		UseHypernetworkModel:        cmdctx.ChannelSettings.HyperNetwork,
-		UseUpscale:                  cmdctx.ChannelSettings.Upscaler,
+		SamplerName:                 settings.Sampler,
+		SessionId:                   settings.SessionID,
+		UseVaeModel:                 settings.VAE,
+		UseHypernetworkModel:        settings.HyperNetwork,
+		UseUpscale:                  settings.Upscaler,
+		UseFaceCorrection:           settings.FaceRestorer,
+		Tiling:                      settings.Tiling,
+	}
+
+	for _, l := range settings.Loras {
+		addLora(data, l.Name, l.Weight)
 	}
 
-	if cmdctx.ChannelSettings.Upscaler != "" {
-		data.UpscaleAmount = strconv.FormatUint(uint64(cmdctx.ChannelSettings.UpscaleAmount), 10)
+	if settings.ControlNet != "" && settings.ControlImage != "" {
+		data.UseControlnetModel = settings.ControlNet
+		data.ControlImage = settings.ControlImage
+		data.ControlAlpha = settings.ControlNetWeight
 	}
 
+	if data.NumOutputs < 1 {
+		data.NumOutputs = 1
+	}
+
+	if data.OutputFormat == "" {
+		data.OutputFormat = "png"
+	}
+
+	if data.OutputQuality < 1 {
+		data.OutputQuality = 75
+	}
+
+	if settings.Upscaler != "" {
+		data.UpscaleAmount = strconv.FormatUint(uint64(settings.UpscaleAmount), 10)
+	}
+
+	return data
+}
+
+func Run(cmdctx *command.CommandContext) error {
+	if record := repliedRecord(cmdctx); record != nil {
+		return renderReply(cmdctx, record)
+	}
+
+	if cmdctx.Args != "" && config.CanChange("prompt") == nil {
+		cmdctx.ChannelSettings.Prompt = utils.TruncateText(cmdctx.Args, 512)
+	}
+
+	data := NewRenderData(cmdctx.ChannelSettings)
+
 	attachments := cmdctx.Message.Attachments
 	hasImageAttachment := len(attachments) > 0 && strings.HasPrefix(attachments[0].ContentType, "image/")
 	if hasImageAttachment {
-		if err := config.CanChange(cmdctx.ChannelSettings.InUse, "img2img"); err != nil {
-			attachment := attachments[0]
-			if err := img2img(cmdctx, attachment, data); err != nil {
-				_, err := cmdctx.TryReply("**Error:** Failed to download image for Img2Img!")
+		if err := config.CanChange("img2img"); err == nil {
+			if err := img2img(cmdctx, attachments, data); err != nil {
+				data.InitImage = ""
+				data.Mask = ""
+
+				_, err := cmdctx.TryReply("**Error:** Failed to load image for Img2Img: %v", err)
 				if err != nil {
 					return err
 				}
@@ -82,45 +253,83 @@ func Run(cmdctx *command.CommandContext) error {
 		}
 	}
 
-	streamurl, task, err := sdapi.Render(data)
+	if cmdctx.ChannelSettings.CombinatorialPrompts && HasAlternatives(data.Prompt) {
+		return enqueueCombinations(cmdctx, data)
+	}
+
+	return Enqueue(cmdctx, data)
+}
+
+func (j *Job) render(slot *sdapi.Slot) error {
+	cmdctx := j.cmdctx
+	msg := j.message
+	data := j.data
+
+	j.mutex.Lock()
+	queued := j.position != 0
+	j.mutex.Unlock()
+
+	// Jobs that started right away already say so
+	if queued {
+		_, _ = cmdctx.Executor.EditMessage(msg.ChannelID, msg.ID, "**Loading...**")
+	}
+
+	controlImage, err := resolveControlImage(data)
 	if err != nil {
-		log.Println("Could not query stable diffusion ui:", err)
+		slot.Release()
+		_ = cmdctx.Executor.DeleteMessage(msg.ChannelID, msg.ID, "render failed")
 		return err
 	}
 
-	msg, err := cmdctx.TryReply("**Loading...**")
+	j.mutex.Lock()
+	j.controlImage = controlImage
+	j.mutex.Unlock()
+
+	task, err := slot.Render(data)
 	if err != nil {
+		log.Println("Could not query stable diffusion ui:", err)
+		_ = cmdctx.Executor.DeleteMessage(msg.ChannelID, msg.ID, "render failed")
 		return err
 	}
 
-	var currentFrame *discord.Message
-	currentStep := uint(0)
+	defer task.Close()
+
 	config.ConfigMutex.Lock()
-	totalSteps := cmdctx.ChannelSettings.InferenceSteps
 	countFrameless := config.Config.CountFrameless
 	errorFrameUrl := config.Config.ErrorFrameUrl
-	stillTyping := true
+	loadingFrameUrl := config.Config.LoadingFrameUrl
+	config.ConfigMutex.Unlock()
+
+	j.mutex.Lock()
+	j.task = task
+	j.lastFrameUrl = loadingFrameUrl
+	stopped := j.stopped
+	j.mutex.Unlock()
 
-	cmdctx.ChannelSettings.CurrentRenderInfoMutex.Lock()
-	cmdctx.ChannelSettings.CurrentRenderInfo = &command.CurrentRenderInfo{
-		RequestedBy:  cmdctx.Message.Author.ID,
-		LastFrameUrl: config.Config.LoadingFrameUrl,
-		Task:         task,
+	if stopped {
+		_ = task.Stop()
 	}
-	cmdctx.ChannelSettings.CurrentRenderInfoMutex.Unlock()
-	config.ConfigMutex.Unlock()
 
-	for currentStep < totalSteps {
-		responses, err := sdapi.GetStream(streamurl)
+	var currentFrame *discord.Message
+	currentStep := uint(0)
+	totalSteps := data.NumInferenceSteps
+
+	// Progress can reach the last step before the images are ready, so only the final response ends the render
+	for {
+		responses, err := task.GetStream()
 		if err != nil {
 			return err
 		}
 
 		var currentResponse *sdapi.StreamResponse
+		final := false
 		for _, response := range responses {
 			if len(response.Output) < 1 || (response.Output[0].Data == "" && response.Output[0].Path == "") {
-				if response.Status != "" && response.Status != "succeeded" {
-					_ = frameEmbed(cmdctx, msg, errorFrameUrl, 0, 0, data.InitImage != "")
+				if response.Status == "succeeded" {
+					_ = frameEmbed(j, errorFrameUrl, 0, 0)
+					return sdapi.ErrNoImages
+				} else if response.Status != "" {
+					_ = frameEmbed(j, errorFrameUrl, 0, 0)
 					return fmt.Errorf("**Error:** Received error from stable diffusion: %s", response.Status)
 				}
 
@@ -131,7 +340,7 @@ func Run(cmdctx *command.CommandContext) error {
 				if response.Step > currentStep {
 					currentStep = response.Step
 					if countFrameless {
-						_ = frameEmbed(cmdctx, msg, "", currentStep, totalSteps, data.InitImage != "")
+						_ = frameEmbed(j, "", currentStep, totalSteps)
 					}
 				}
 
@@ -141,6 +350,7 @@ func Run(cmdctx *command.CommandContext) error {
 			if response.Status == "succeeded" {
 				currentStep = totalSteps
 				currentResponse = &response
+				final = true
 				break
 			}
 
@@ -157,18 +367,29 @@ func Run(cmdctx *command.CommandContext) error {
 			continue
 		}
 
-		if stillTyping {
-			cmdctx.StopTyping <- struct{}{}
-			stillTyping = false
-		}
+		cmdctx.StopTypingOnce()
 
 		if currentResponse.TotalSteps != 0 {
 			totalSteps = currentResponse.TotalSteps
 		}
 
+		if final {
+			err := j.finish(task, currentResponse.Output, totalSteps)
+			if currentFrame != nil {
+				_ = cmdctx.Executor.DeleteMessage(currentFrame.ChannelID, currentFrame.ID, "progress frame")
+			}
+
+			if err != nil {
+				_, _ = cmdctx.Executor.EditMessage(msg.ChannelID, msg.ID, fmt.Sprintf("**Error:** Failed to upload image: %v", err))
+			}
+
+			return nil
+		}
+
 		if currentResponse.Output[0].Data != "" {
-			b64body := base64.NewDecoder(base64.StdEncoding, strings.NewReader(currentResponse.Output[0].Data[22:]))
-			_, err := frame(cmdctx, msg, b64body, currentStep, totalSteps, data.InitImage != "")
+			dataURL := currentResponse.Output[0].Data
+			b64body := base64.NewDecoder(base64.StdEncoding, strings.NewReader(dataURL[strings.IndexByte(dataURL, ',')+1:]))
+			_, err := frame(j, b64body, currentStep, totalSteps)
 			if currentFrame != nil {
 				_ = cmdctx.Executor.DeleteMessage(currentFrame.ChannelID, currentFrame.ID, "progress frame")
 			}
@@ -180,13 +401,13 @@ func Run(cmdctx *command.CommandContext) error {
 			continue
 		}
 
-		image, err := sdapi.GetImage(currentResponse.Output[0].Path)
+		image, err := task.GetImage(currentResponse.Output[0].Path)
 		if err != nil {
 			_, _ = cmdctx.Executor.EditMessage(msg.ChannelID, msg.ID, fmt.Sprintf("**Error:** Failed to get image: %v", err))
 			continue
 		}
 
-		f, err := frame(cmdctx, msg, image, currentStep, totalSteps, data.InitImage != "")
+		f, err := frame(j, image, currentStep, totalSteps)
 		if currentFrame != nil {
 			_ = cmdctx.Executor.DeleteMessage(currentFrame.ChannelID, currentFrame.ID, "progress frame")
 		}
@@ -197,6 +418,4 @@ func Run(cmdctx *command.CommandContext) error {
 			_, _ = cmdctx.Executor.EditMessage(msg.ChannelID, msg.ID, fmt.Sprintf("**Error:** Failed to upload image: %v", err))
 		}
 	}
-
-	return nil
 }
@@ -0,0 +1,75 @@
+package render
+
+import (
+	"math/rand"
+	"strings"
+
+	"github.com/ayunami2000/ayunsdcord/commands/command"
+	"github.com/ayunami2000/ayunsdcord/config"
+	"github.com/ayunami2000/ayunsdcord/utils"
+)
+
+// The render the message replies to, if it is one
+func repliedRecord(cmdctx *command.CommandContext) *Record {
+	ref := cmdctx.Message.ReferencedMessage
+	if ref == nil {
+		return nil
+	}
+
+	record, err := GetRecord(ref.ID)
+	if err != nil {
+		return nil
+	}
+
+	return record
+}
+
+// The render the message replies to, or else the last render of the channel
+func TargetRecord(cmdctx *command.CommandContext) (*Record, error) {
+	if record := repliedRecord(cmdctx); record != nil {
+		return record, nil
+	}
+
+	return LastRecord(cmdctx.Message.ChannelID)
+}
+
+// Renders again from the parameters and image of a previous render instead of the channel settings,
+// with the arguments as the new prompt, so people can iterate on different renders in one channel
+func renderReply(cmdctx *command.CommandContext, record *Record) error {
+	data := record.RenderData()
+	data.Seed = int(rand.Int31())
+
+	if cmdctx.Args != "" {
+		if err := config.CanChange("prompt"); err != nil {
+			return err
+		}
+
+		data.Prompt = utils.TruncateText(cmdctx.Args, 512)
+		data.OriginalPrompt = data.Prompt
+	}
+
+	if err := config.CanChange("img2img"); err != nil {
+		return err
+	}
+
+	attachments := cmdctx.Message.Attachments
+	if len(attachments) > 0 && strings.HasPrefix(attachments[0].ContentType, "image/") {
+		if err := img2img(cmdctx, attachments, data); err != nil {
+			return err
+		}
+	} else {
+		imageURL, err := record.Image(0)
+		if err != nil {
+			return err
+		}
+
+		if err := loadInitImage(imageURL, data); err != nil {
+			return err
+		}
+
+		data.Mask = ""
+		data.PromptStrength = cmdctx.ChannelSettings.PromptStrength
+	}
+
+	return Enqueue(cmdctx, data)
+}
@@ -0,0 +1,159 @@
+package render
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/ayunami2000/ayunsdcord/sdapi"
+	"github.com/ayunami2000/ayunsdcord/utils"
+	"github.com/diamondburned/arikawa/v3/utils/sendpart"
+)
+
+func readOutput(task *sdapi.Task, output sdapi.StreamOutput) ([]byte, error) {
+	if output.Data != "" {
+		return base64.StdEncoding.DecodeString(output.Data[strings.IndexByte(output.Data, ',')+1:])
+	}
+
+	image, err := task.GetImage(output.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	defer image.Close()
+	return io.ReadAll(image)
+}
+
+// Discord allows this many attachments per message
+const maxAttachments = 10
+
+// Uploads the final images, with a grid of them in front for batches and a tiled preview behind for tiling renders, and records the render
+func (j *Job) finish(task *sdapi.Task, outputs []sdapi.StreamOutput, totalSteps uint) error {
+	images := [][]byte{}
+	for _, output := range outputs {
+		image, err := readOutput(task, output)
+		if err != nil {
+			return err
+		}
+
+		image, err = encodeOutput(image, j.data.OutputFormat, j.data.OutputQuality)
+		if err != nil {
+			return err
+		}
+
+		tagged, err := utils.SetPNGText(image, "parameters", sdapi.FormatParameters(j.data, len(images)))
+		if err == nil {
+			image = tagged
+		} else if !errors.Is(err, utils.ErrNotPNG) {
+			return err
+		}
+
+		images = append(images, image)
+	}
+
+	if j.plotCell {
+		j.mutex.Lock()
+		j.finished = true
+		j.images = images
+		j.mutex.Unlock()
+
+		return nil
+	}
+
+	uploads := images
+	if len(images) > 1 {
+		decoded, err := decodeImages(images)
+		if err != nil {
+			return err
+		}
+
+		grid, err := encodeAs(makeGrid(decoded), j.data.OutputFormat, j.data.OutputQuality)
+		if err != nil {
+			return err
+		}
+
+		uploads = append([][]byte{grid}, images...)
+	}
+
+	hasTilePreview := j.data.Tiling != "" && len(uploads) < maxAttachments
+	if hasTilePreview {
+		decoded, err := decodeImages(images[:1])
+		if err != nil {
+			return err
+		}
+
+		preview, err := encodeAs(makeTilePreview(decoded[0]), j.data.OutputFormat, j.data.OutputQuality)
+		if err != nil {
+			return err
+		}
+
+		uploads = append(uploads, preview)
+	}
+
+	uploads, err := fitUploads(uploads, j.data.OutputQuality)
+	if err != nil {
+		return err
+	}
+
+	files := []sendpart.File{}
+	for i, upload := range uploads {
+		files = append(files, sendpart.File{
+			Name:   fmt.Sprintf("stable-diffusion_%d_%d.%s", time.Now().UnixNano(), i, imageExtension(upload)),
+			Reader: bytes.NewReader(upload),
+		})
+	}
+
+	msg, err := dump(j, files)
+	if err != nil {
+		return err
+	}
+
+	imageURLs := []string{}
+	for _, attachment := range msg.Attachments {
+		imageURLs = append(imageURLs, attachment.URL)
+	}
+
+	tilePreviewUrl := ""
+	if hasTilePreview {
+		tilePreviewUrl = imageURLs[len(imageURLs)-1]
+		imageURLs = imageURLs[:len(imageURLs)-1]
+	}
+
+	imageURL := imageURLs[0]
+	if len(images) > 1 {
+		imageURLs = imageURLs[1:]
+	}
+
+	j.mutex.Lock()
+	j.finished = true
+	j.images = images
+	j.imageURLs = imageURLs
+	j.upload = Upload{ChannelID: msg.ChannelID, MessageID: msg.ID}
+	j.tilePreviewUrl = tilePreviewUrl
+	controlImage := j.controlImage
+	j.mutex.Unlock()
+
+	record := &Record{
+		RequestedBy: j.RequestedBy,
+		ChannelID:   j.message.ChannelID,
+		GuildID:     j.cmdctx.Message.GuildID,
+		Data:        *j.data,
+		ImageURL:    imageURL,
+		ImageURLs:   imageURLs,
+		Upload:      Upload{ChannelID: msg.ChannelID, MessageID: msg.ID},
+	}
+	if controlImage != "" {
+		record.Data.ControlImage = controlImage
+	}
+
+	if err = saveRecord(j.message.ID, record); err != nil {
+		log.Println("Could not save render:", err)
+	}
+
+	return frameEmbed(j, imageURL, totalSteps, totalSteps)
+}
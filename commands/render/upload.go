@@ -0,0 +1,99 @@
+package render
+
+import (
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/diamondburned/arikawa/v3/discord"
+)
+
+// Looks up the messages images were uploaded in, set once the bot is connected
+var FetchMessage func(channelID discord.ChannelID, messageID discord.MessageID) (*discord.Message, error)
+
+// The message the images of a render were uploaded in, Discord's attachment links expire but the message keeps handing out fresh ones
+type Upload struct {
+	ChannelID discord.ChannelID
+	MessageID discord.MessageID
+}
+
+type freshLinks struct {
+	links   []string
+	expires time.Time
+}
+
+var freshLinksMutex sync.Mutex
+var freshLinksCache = map[discord.MessageID]freshLinks{}
+
+// When a Discord attachment link stops working, links without an expiry are from before Discord added them and no longer work
+func linkExpiry(link string) time.Time {
+	parsed, err := url.Parse(link)
+	if err != nil {
+		return time.Time{}
+	}
+
+	expiry, err := strconv.ParseInt(parsed.Query().Get("ex"), 16, 64)
+	if err != nil {
+		return time.Time{}
+	}
+
+	return time.Unix(expiry, 0)
+}
+
+// Attachment links that work for at least another hour, taken from the upload message if the stored ones don't
+func (u Upload) links(stored []string, offset int) []string {
+	soon := time.Now().Add(time.Hour)
+	expired := false
+	for _, link := range stored {
+		if linkExpiry(link).Before(soon) {
+			expired = true
+			break
+		}
+	}
+
+	if !expired || !u.MessageID.IsValid() || FetchMessage == nil {
+		return stored
+	}
+
+	freshLinksMutex.Lock()
+	cached, exists := freshLinksCache[u.MessageID]
+	freshLinksMutex.Unlock()
+
+	if exists && cached.expires.After(soon) {
+		return cached.links
+	}
+
+	msg, err := FetchMessage(u.ChannelID, u.MessageID)
+	if err != nil || len(msg.Attachments) < offset+len(stored) {
+		return stored
+	}
+
+	fresh := freshLinks{expires: time.Now().Add(24 * time.Hour)}
+	for _, attachment := range msg.Attachments[offset : offset+len(stored)] {
+		fresh.links = append(fresh.links, attachment.URL)
+		if expiry := linkExpiry(attachment.URL); !expiry.IsZero() && expiry.Before(fresh.expires) {
+			fresh.expires = expiry
+		}
+	}
+
+	freshLinksMutex.Lock()
+	for id, c := range freshLinksCache {
+		if c.expires.Before(time.Now()) {
+			delete(freshLinksCache, id)
+		}
+	}
+	freshLinksCache[u.MessageID] = fresh
+	freshLinksMutex.Unlock()
+
+	return fresh.links
+}
+
+// Batches have their grid in front of the images in the upload message
+func imagesOffset(images []string) int {
+	if len(images) > 1 {
+		return 1
+	}
+
+	return 0
+}
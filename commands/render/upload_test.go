@@ -0,0 +1,43 @@
+package render
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLinkExpiry(t *testing.T) {
+	tests := []struct {
+		name string
+		link string
+		want time.Time
+	}{
+		{
+			name: "signed",
+			link: "https://cdn.discordapp.com/attachments/1/2/image.png?ex=65f1a2b3&is=65df2db3&hm=abc&",
+			want: time.Unix(0x65f1a2b3, 0),
+		},
+		{
+			name: "unsigned",
+			link: "https://cdn.discordapp.com/attachments/1/2/image.png",
+		},
+		{
+			name: "invalid expiry",
+			link: "https://cdn.discordapp.com/attachments/1/2/image.png?ex=soon",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := linkExpiry(test.link); !got.Equal(test.want) {
+				t.Errorf("linkExpiry() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestUploadLinksWithoutMessage(t *testing.T) {
+	stored := []string{"https://cdn.discordapp.com/attachments/1/2/image.png"}
+	if got := (Upload{}).links(stored, 0); len(got) != 1 || got[0] != stored[0] {
+		t.Errorf("links() = %v, want %v", got, stored)
+	}
+}
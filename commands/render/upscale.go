@@ -0,0 +1,58 @@
+package render
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/ayunami2000/ayunsdcord/commands/command"
+	"github.com/ayunami2000/ayunsdcord/sdapi"
+)
+
+// Queues an upscale of the image like a render and waits for it, the result is nil if it was stopped
+func Upscale(cmdctx *command.CommandContext, img []byte, upscaler string, amount uint) ([]byte, error) {
+	msg, position, err := replyQueued(cmdctx)
+	if err != nil {
+		return nil, err
+	}
+
+	job := &Job{
+		cmdctx:      cmdctx,
+		data:        &sdapi.RenderData{UseUpscale: upscaler, UpscaleAmount: strconv.FormatUint(uint64(amount), 10)},
+		message:     msg,
+		position:    position,
+		RequestedBy: cmdctx.Message.Author.ID,
+		done:        make(chan struct{}),
+	}
+
+	var upscaled []byte
+	job.work = func(slot *sdapi.Slot) error {
+		_, _ = cmdctx.Executor.EditMessage(msg.ChannelID, msg.ID, "**Upscaling...**")
+
+		result, err := slot.Upscale(img, upscaler, amount)
+		if err != nil {
+			_, _ = cmdctx.Executor.EditMessage(msg.ChannelID, msg.ID, fmt.Sprintf("**Error:** Failed to upscale: %v", err))
+			return err
+		}
+
+		// Upscalers can't be interrupted, so a stopped upscale only drops its result
+		job.mutex.Lock()
+		stopped := job.stopped
+		job.finished = !stopped
+		job.mutex.Unlock()
+
+		if stopped {
+			_, _ = cmdctx.Executor.EditMessage(msg.ChannelID, msg.ID, "**Stopped.**")
+			return nil
+		}
+
+		upscaled = result
+		_ = cmdctx.Executor.DeleteMessage(msg.ChannelID, msg.ID, "upscale finished")
+		return nil
+	}
+
+	cmdctx.DoneWithSettings()
+
+	queue.push(job)
+	<-job.done
+	return upscaled, job.err
+}
@@ -0,0 +1,41 @@
+package render
+
+import (
+	"math/rand"
+
+	"github.com/ayunami2000/ayunsdcord/commands/command"
+	"github.com/ayunami2000/ayunsdcord/config"
+	xdraw "golang.org/x/image/draw"
+)
+
+// Default strength of variations, low enough to keep the composition of the original
+const VariationStrength = 0.35
+
+// Renders variants of one image of a render, with its seed and a random subseed
+func Variation(cmdctx *command.CommandContext, record *Record, index int, strength float64, count uint) error {
+	if err := config.CanChange("variation"); err != nil {
+		return err
+	}
+
+	imageURL, err := record.Image(index)
+	if err != nil {
+		return err
+	}
+
+	img, err := DownloadImage(imageURL)
+	if err != nil {
+		return err
+	}
+
+	data := record.RenderData()
+	if data.VariationImage, err = pngDataURL(resizeImage(img, data.Width, data.Height, xdraw.CatmullRom)); err != nil {
+		return err
+	}
+
+	data.Seed, _ = record.Data.ImageSeeds(index)
+	data.Subseed = int(rand.Int31())
+	data.SubseedStrength = strength
+	data.NumOutputs = count
+
+	return Enqueue(cmdctx, data)
+}
@@ -0,0 +1,119 @@
+package render
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/ayunami2000/ayunsdcord/config"
+)
+
+var ErrUnknownWildcard = errors.New("unknown wildcard")
+var ErrEmptyWildcard = errors.New("wildcard file has no lines")
+var ErrWildcardTooDeep = errors.New("wildcards are nested too deeply")
+
+var wildcardRegex = regexp.MustCompile(`__([\w-]+(?:/[\w-]+)*)__`)
+
+// Wildcard files can use other wildcards, but not forever
+const maxWildcardDepth = 10
+
+func wildcardsDir() string {
+	config.ConfigMutex.Lock()
+	defer config.ConfigMutex.Unlock()
+
+	return config.Config.WildcardsDir
+}
+
+func HasWildcards(prompt string) bool {
+	return wildcardRegex.MatchString(prompt)
+}
+
+// Whether the text is a single wildcard and nothing else, like __name__
+func IsWildcard(text string) bool {
+	return wildcardRegex.FindString(text) == text
+}
+
+// Names of all wildcard files, without the .txt
+func ListWildcards() ([]string, error) {
+	dir := wildcardsDir()
+	names := []string{}
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if !d.IsDir() && strings.HasSuffix(path, ".txt") {
+			rel, err := filepath.Rel(dir, path)
+			if err != nil {
+				return err
+			}
+
+			names = append(names, strings.TrimSuffix(filepath.ToSlash(rel), ".txt"))
+		}
+
+		return nil
+	})
+
+	if errors.Is(err, fs.ErrNotExist) {
+		return names, nil
+	}
+
+	sort.Strings(names)
+	return names, err
+}
+
+// Lines of a wildcard file, skipping blank ones and # comments
+func readWildcard(name string) ([]string, error) {
+	raw, err := os.ReadFile(filepath.Join(wildcardsDir(), filepath.FromSlash(name)+".txt"))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownWildcard, name)
+	} else if err != nil {
+		return nil, err
+	}
+
+	lines := []string{}
+	for _, line := range strings.Split(string(raw), "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "#") {
+			lines = append(lines, line)
+		}
+	}
+
+	if len(lines) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrEmptyWildcard, name)
+	}
+
+	return lines, nil
+}
+
+// Replaces every __name__ in the prompt with a random line of its file
+func ExpandWildcards(prompt string) (string, error) {
+	for depth := 0; HasWildcards(prompt); depth++ {
+		if depth == maxWildcardDepth {
+			return "", ErrWildcardTooDeep
+		}
+
+		var err error
+		prompt = wildcardRegex.ReplaceAllStringFunc(prompt, func(match string) string {
+			lines, e := readWildcard(wildcardRegex.FindStringSubmatch(match)[1])
+			if e != nil {
+				err = e
+				return ""
+			}
+
+			return lines[rand.Intn(len(lines))]
+		})
+
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return prompt, nil
+}
@@ -0,0 +1,113 @@
+package render
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"strings"
+
+	"github.com/ayunami2000/ayunsdcord/commands/command"
+	"github.com/ayunami2000/ayunsdcord/sdapi"
+)
+
+var ErrPlotIncomplete = errors.New("not every render of the plot finished")
+
+// One side of an X/Y plot
+type Axis struct {
+	Name   string
+	Values []string
+}
+
+func (a *Axis) labels() []string {
+	labels := []string{}
+	for _, v := range a.Values {
+		labels = append(labels, fmt.Sprintf("%s: %s", a.Name, v))
+	}
+
+	return labels
+}
+
+// Renders every cell, given row by row, and posts them together as one labelled grid
+func RenderXY(cmdctx *command.CommandContext, cells []*sdapi.RenderData, x Axis, y Axis) error {
+	for _, data := range cells {
+		if err := preparePrompt(data); err != nil {
+			return err
+		}
+	}
+
+	// The cells share one message, which shows the queue position of the plot and the progress of the cell that is rendering
+	msg, position, err := replyQueued(cmdctx)
+	if err != nil {
+		return err
+	}
+
+	cmdctx.DoneWithSettings()
+
+	// Queued one after another so the cells render row by row
+	jobs := []*Job{}
+	for _, data := range cells {
+		job := &Job{
+			cmdctx:      cmdctx,
+			data:        data,
+			message:     msg,
+			position:    position,
+			RequestedBy: cmdctx.Message.Author.ID,
+			done:        make(chan struct{}),
+			plotCell:    true,
+		}
+
+		queue.push(job)
+		jobs = append(jobs, job)
+	}
+
+	// The plot can't be made once a cell failed, so the cells after it are not rendered for nothing
+	for _, job := range jobs {
+		<-job.done
+		if job.err != nil {
+			_ = job.Stop()
+			err = job.err
+			break
+		}
+	}
+
+	if err == nil {
+		err = wait(jobs)
+	}
+	_ = cmdctx.Executor.DeleteMessage(msg.ChannelID, msg.ID, "x/y plot finished")
+	if err != nil {
+		return err
+	}
+
+	images := []image.Image{}
+	for _, job := range jobs {
+		job.mutex.Lock()
+		finished := job.images
+		job.mutex.Unlock()
+
+		if len(finished) == 0 {
+			return ErrPlotIncomplete
+		}
+
+		decoded, err := decodeImages(finished[:1])
+		if err != nil {
+			return err
+		}
+
+		images = append(images, decoded[0])
+	}
+
+	grid, err := encodeAs(makeXYGrid(images, x.labels(), y.labels()), cells[0].OutputFormat, cells[0].OutputQuality)
+	if err != nil {
+		return err
+	}
+
+	desc := fmt.Sprintf("**X:** %s (%s)", x.Name, strings.Join(x.Values, ", "))
+	if len(y.Values) > 0 {
+		desc += fmt.Sprintf("\n**Y:** %s (%s)", y.Name, strings.Join(y.Values, ", "))
+	}
+	if x.Name != "seed" && y.Name != "seed" {
+		desc += fmt.Sprintf("\n**Seed:** %d", cells[0].Seed)
+	}
+
+	return ReplyImage(cmdctx, "X/Y Plot", desc, "xy", grid, cells[0].OutputQuality)
+}
@@ -0,0 +1,53 @@
+package commands
+
+import (
+	"errors"
+	"regexp"
+
+	"github.com/ayunami2000/ayunsdcord/commands/command"
+	"github.com/ayunami2000/ayunsdcord/commands/render"
+	"github.com/diamondburned/arikawa/v3/discord"
+)
+
+var ReproduceCommand = command.NewCommand("reproduce", []string{"rp"}, reproduceRun).Describe("Renders a previous result again with the exact same parameters",
+	command.Option{Name: "message", Description: "Link to the message of the render", Type: command.StringOption, Required: true})
+var ErrInvalidMessageLink = errors.New("invalid message link")
+
+var messageLinkRegex = regexp.MustCompile(`channels/(?:\d+|@me)/\d+/(\d+)`)
+
+// Accepts a message link or a bare message ID
+func parseMessageLink(link string) (discord.MessageID, error) {
+	if match := messageLinkRegex.FindStringSubmatch(link); match != nil {
+		link = match[1]
+	}
+
+	id, err := discord.ParseSnowflake(link)
+	if err != nil {
+		return 0, ErrInvalidMessageLink
+	}
+
+	return discord.MessageID(id), nil
+}
+
+func reproduceRun(cmdctx *command.CommandContext) error {
+	if cmdctx.Args == "" {
+		_, err := cmdctx.TryReply("**Please specify a message link!**")
+		return err
+	}
+
+	id, err := parseMessageLink(cmdctx.Args)
+	if err != nil {
+		return err
+	}
+
+	record, err := render.GetRecord(id)
+	if err != nil {
+		return err
+	}
+
+	if !record.VisibleFrom(cmdctx.Message) {
+		return render.ErrRecordElsewhere
+	}
+
+	return render.Enqueue(cmdctx, record.RenderData())
+}
@@ -6,25 +6,31 @@ import (
 
 	"github.com/ayunami2000/ayunsdcord/commands/command"
 	"github.com/ayunami2000/ayunsdcord/config"
+	"github.com/ayunami2000/ayunsdcord/sdapi"
 )
 
-var VALID_SAMPLERS = []string{"plms", "ddim", "heun", "euler", "euler_a", "dpm2", "dpm2_a", "lms", "dpm_solver_stability", "dpmpp_2s_a", "dpmpp_2m", "dpmpp_sde", "dpm_fast", "dpm_adaptive", "unipc_snr", "unipc_tu", "unipc_snr_2", "unipc_tu_2", "unipc_tq"}
-var SamplerCommand = command.NewCommand("sampler", []string{"sm"}, samplerCommandRun)
+var SamplerCommand = command.NewCommand("sampler", []string{"sm"}, samplerCommandRun).Describe("Shows or sets the sampler",
+	command.Option{Name: "name", Description: "Sampler to use", Type: command.StringOption, Autocomplete: sdapi.GetSamplers})
 var ErrInvalidSampler = errors.New("invalid sampler")
 
 func samplerCommandRun(cmdctx *command.CommandContext) error {
+	samplers, err := sdapi.GetSamplers()
+	if err != nil {
+		return err
+	}
+
 	if cmdctx.Args == "" {
 		_, err := cmdctx.TryReply(`**Current Sampler:** %s
-Samplers: %s`, cmdctx.ChannelSettings.Sampler, strings.Join(VALID_SAMPLERS, ", "))
+Samplers: %s`, cmdctx.ChannelSettings.Sampler, strings.Join(samplers, ", "))
 		return err
 	}
 
-	if err := config.CanChange(cmdctx.ChannelSettings.InUse, "sampler"); err != nil {
+	if err := config.CanChange("sampler"); err != nil {
 		return err
 	}
 
 	sampler := ""
-	for _, s := range VALID_SAMPLERS {
+	for _, s := range samplers {
 		if strings.EqualFold(s, cmdctx.Args) {
 			sampler = s
 			break
@@ -37,6 +43,6 @@ Samplers: %s`, cmdctx.ChannelSettings.Sampler, strings.Join(VALID_SAMPLERS, ", "
 
 	cmdctx.ChannelSettings.Sampler = sampler
 
-	_, err := cmdctx.TryReply("**Sampler set to:** %s", sampler)
+	_, err = cmdctx.TryReply("**Sampler set to:** %s", sampler)
 	return err
 }
@@ -0,0 +1,45 @@
+package commands
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/ayunami2000/ayunsdcord/commands/command"
+	"github.com/ayunami2000/ayunsdcord/config"
+)
+
+var SeedCommand = command.NewCommand("seed", []string{"se"}, seedRun).Describe("Shows or sets the seed",
+	command.Option{Name: "seed", Description: "Seed to use, or random", Type: command.StringOption})
+
+func seedText(seed int) string {
+	if seed < 0 {
+		return "random"
+	}
+
+	return strconv.Itoa(seed)
+}
+
+func seedRun(cmdctx *command.CommandContext) error {
+	if cmdctx.Args == "" {
+		_, err := cmdctx.TryReply("**Current seed:** %s", seedText(cmdctx.ChannelSettings.Seed))
+		return err
+	}
+
+	if err := config.CanChange("seed"); err != nil {
+		return err
+	}
+
+	if strings.EqualFold(cmdctx.Args, "random") {
+		cmdctx.ChannelSettings.Seed = -1
+	} else {
+		i, err := strconv.ParseUint(cmdctx.Args, 10, 32)
+		if err != nil {
+			return err
+		}
+
+		cmdctx.ChannelSettings.Seed = int(i)
+	}
+
+	_, err := cmdctx.TryReply("**Seed set to:** %s", seedText(cmdctx.ChannelSettings.Seed))
+	return err
+}
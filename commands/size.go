@@ -11,7 +11,9 @@ import (
 )
 
 var VALID_SIZES = []uint64{128, 192, 256, 320, 384, 448, 512, 576, 640, 704, 768, 832, 896, 960, 1024, 1280, 1536, 1792, 2048}
-var SizeCommand = command.NewCommand("size", []string{"sz"}, sizeRun)
+var SizeCommand = command.NewCommand("size", []string{"sz"}, sizeRun).Describe("Shows or sets the image size",
+	command.Option{Name: "width", Description: "Width, also used as height if no height is given", Type: command.IntegerOption, Choices: utils.ToStringSlice(VALID_SIZES)},
+	command.Option{Name: "height", Description: "Height", Type: command.IntegerOption, Choices: utils.ToStringSlice(VALID_SIZES)})
 var ErrInvalidSize = errors.New("invalid size")
 
 func parseSize(sz string) (uint, error) {
@@ -50,7 +52,7 @@ Sizes: %s`, cmdctx.ChannelSettings.Width, cmdctx.ChannelSettings.Height, strings
 		return err
 	}
 
-	if err := config.CanChange(cmdctx.ChannelSettings.InUse, "size"); err != nil {
+	if err := config.CanChange("size"); err != nil {
 		return err
 	}
 
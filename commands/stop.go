@@ -4,36 +4,37 @@ import (
 	"errors"
 
 	"github.com/ayunami2000/ayunsdcord/commands/command"
+	"github.com/ayunami2000/ayunsdcord/commands/render"
 	"github.com/ayunami2000/ayunsdcord/config"
-	"github.com/ayunami2000/ayunsdcord/sdapi"
 )
 
 var ErrRenderNotInProgress = errors.New("no render in progress")
 var ErrRenderNotRequestedByYou = errors.New("current render not requested by you")
-var StopCommand = command.NewCommand("stop", []string{"s"}, stopRun)
+var StopCommand = command.NewCommand("stop", []string{"s"}, stopRun).Describe("Stops your renders in this channel")
 
 func stopRun(cmdctx *command.CommandContext) error {
-	if !config.CanChange_NoLock("stop") {
-		return ErrCannotChangeProperty
+	if err := config.CanChange("stop"); err != nil {
+		return err
 	}
 
-	if !cmdctx.ChannelSettings.InUse.Load() {
+	jobs := render.ChannelJobs(cmdctx.Message.ChannelID)
+	if len(jobs) == 0 {
 		return ErrRenderNotInProgress
 	}
 
-	cmdctx.ChannelSettings.CurrentRenderInfoMutex.Lock()
-	defer cmdctx.ChannelSettings.CurrentRenderInfoMutex.Unlock()
-
-	renderinfo := cmdctx.ChannelSettings.CurrentRenderInfo
-	if renderinfo == nil || renderinfo.Task == 0 {
-		return ErrRenderNotInProgress
+	// Stop the most recent render of the author
+	var job *render.Job
+	for _, j := range jobs {
+		if j.RequestedBy == cmdctx.Message.Author.ID {
+			job = j
+		}
 	}
 
-	if renderinfo.RequestedBy != cmdctx.Message.Author.ID {
+	if job == nil {
 		return ErrRenderNotRequestedByYou
 	}
 
-	if err := sdapi.StopRender(renderinfo.Task); err != nil {
+	if err := job.Stop(); err != nil {
 		return err
 	}
 
@@ -0,0 +1,63 @@
+package commands
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/ayunami2000/ayunsdcord/commands/command"
+	"github.com/ayunami2000/ayunsdcord/config"
+	"github.com/ayunami2000/ayunsdcord/sdapi"
+	"github.com/ayunami2000/ayunsdcord/utils"
+)
+
+var TilingCommand = command.NewCommand("tiling", []string{"ti"}, tilingRun).Describe("Shows or sets the axes renders tile seamlessly along",
+	command.Option{Name: "axes", Description: "Axes to tile along", Type: command.StringOption, Choices: []string{"none", "x", "y", "xy"}})
+var ErrInvalidTiling = errors.New("invalid tiling, valid values: none, x, y, xy")
+var ErrUnsupportedTiling = errors.New("tiling along these axes is not supported by the backend")
+
+// Validates the tiling mode against the backend, "none" turns tiling off
+func setTiling(settings *command.ChannelSettings, value string) error {
+	if err := config.CanChange("tiling"); err != nil {
+		return err
+	}
+
+	mode := strings.ToLower(value)
+	if mode == "none" || mode == "off" {
+		settings.Tiling = ""
+		return nil
+	}
+
+	if mode == "yx" {
+		mode = "xy"
+	}
+
+	if mode != "x" && mode != "y" && mode != "xy" {
+		return ErrInvalidTiling
+	}
+
+	modes, err := sdapi.GetTilingModes()
+	if err != nil {
+		return err
+	}
+
+	if !utils.Contains(modes, mode) {
+		return ErrUnsupportedTiling
+	}
+
+	settings.Tiling = mode
+	return nil
+}
+
+func tilingRun(cmdctx *command.CommandContext) error {
+	if cmdctx.Args == "" {
+		_, err := cmdctx.TryReply("**Current tiling:** %s", utils.StringOrNone(cmdctx.ChannelSettings.Tiling))
+		return err
+	}
+
+	if err := setTiling(cmdctx.ChannelSettings, cmdctx.Args); err != nil {
+		return err
+	}
+
+	_, err := cmdctx.TryReply("**Tiling set to:** %s", utils.StringOrNone(cmdctx.ChannelSettings.Tiling))
+	return err
+}
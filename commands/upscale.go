@@ -0,0 +1,111 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/ayunami2000/ayunsdcord/commands/command"
+	"github.com/ayunami2000/ayunsdcord/commands/render"
+	"github.com/ayunami2000/ayunsdcord/sdapi"
+	"github.com/ayunami2000/ayunsdcord/utils"
+	"github.com/diamondburned/arikawa/v3/discord"
+)
+
+var UpscaleCommand = command.NewCommand("upscale", []string{"up"}, upscaleRun).Describe("Upscales an attached image, or the render replied to",
+	command.Option{Name: "amount", Description: "Upscale factor, defaults to the upscale amount", Type: command.IntegerOption, Choices: utils.ToStringSlice(VALID_UPSCALE_AMOUNTS)},
+	command.Option{Name: "upscaler", Description: "Upscaler to use, defaults to the current one", Type: command.StringOption, Autocomplete: sdapi.GetUpscalers},
+	command.Option{Name: "image", Description: "Image to upscale", Type: command.AttachmentOption})
+var ErrNoImage = errors.New("please attach an image or reply to a render")
+
+func imageAttachment(attachments []discord.Attachment) string {
+	for _, attachment := range attachments {
+		if strings.HasPrefix(attachment.ContentType, "image/") {
+			return attachment.URL
+		}
+	}
+
+	return ""
+}
+
+// The attached image, or else the first image of the message replied to
+func replyImage(msg *discord.Message) (string, error) {
+	if url := imageAttachment(msg.Attachments); url != "" {
+		return url, nil
+	}
+
+	ref := msg.ReferencedMessage
+	if ref == nil {
+		return "", ErrNoImage
+	}
+
+	if record, err := render.GetRecord(ref.ID); err == nil {
+		return record.Image(0)
+	}
+
+	for _, embed := range ref.Embeds {
+		if embed.Image != nil && embed.Image.URL != "" {
+			return embed.Image.URL, nil
+		}
+	}
+
+	if url := imageAttachment(ref.Attachments); url != "" {
+		return url, nil
+	}
+
+	return "", ErrNoImage
+}
+
+func upscaleRun(cmdctx *command.CommandContext) error {
+	imageURL, err := replyImage(cmdctx.Message)
+	if err != nil {
+		return err
+	}
+
+	amount := cmdctx.ChannelSettings.UpscaleAmount
+	if amount == 0 {
+		amount = VALID_UPSCALE_AMOUNTS[0]
+	}
+
+	name := cmdctx.Args
+	if first, rest, _ := strings.Cut(name, " "); first != "" {
+		if i, err := strconv.ParseUint(first, 10, 64); err == nil {
+			if !utils.Contains(VALID_UPSCALE_AMOUNTS, uint(i)) {
+				return ErrInvalidUpscaleAmount
+			}
+
+			amount = uint(i)
+			name = strings.TrimSpace(rest)
+		}
+	}
+
+	upscalers, err := sdapi.GetUpscalers()
+	if err != nil {
+		return err
+	}
+
+	upscaler := cmdctx.ChannelSettings.Upscaler
+	if name != "" {
+		upscaler = findFold(upscalers, name)
+	} else if upscaler == "" && len(upscalers) > 0 {
+		upscaler = upscalers[0]
+	}
+
+	if upscaler == "" {
+		return ErrInvalidUpscaler
+	}
+
+	raw, err := downloadAttachment(imageURL)
+	if err != nil {
+		return err
+	}
+
+	quality := cmdctx.ChannelSettings.OutputQuality
+	upscaled, err := render.Upscale(cmdctx, raw, upscaler, amount)
+	if err != nil || upscaled == nil {
+		return err
+	}
+
+	return render.ReplyImage(cmdctx, "Upscaled", fmt.Sprintf("**Upscaler:** %dx %s", amount, upscaler), "upscale", upscaled, quality)
+}
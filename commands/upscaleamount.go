@@ -11,7 +11,8 @@ import (
 )
 
 var VALID_UPSCALE_AMOUNTS = []uint{2, 4}
-var UpscaleAmountCommand = command.NewCommand("upscaleamount", []string{"ua"}, upscaleAmountRun)
+var UpscaleAmountCommand = command.NewCommand("upscaleamount", []string{"ua"}, upscaleAmountRun).Describe("Shows or sets the upscale amount",
+	command.Option{Name: "amount", Description: "Upscale factor", Type: command.IntegerOption, Choices: utils.ToStringSlice(VALID_UPSCALE_AMOUNTS)})
 var ErrInvalidUpscaleAmount = errors.New("invalid upscale amount")
 
 func upscaleAmountRun(cmdctx *command.CommandContext) error {
@@ -21,7 +22,7 @@ Upscale amounts: %s`, cmdctx.ChannelSettings.UpscaleAmount, strings.Join(utils.T
 		return err
 	}
 
-	if err := config.CanChange(cmdctx.ChannelSettings.InUse, "upscaleamount"); err != nil {
+	if err := config.CanChange("upscaleamount"); err != nil {
 		return err
 	}
 
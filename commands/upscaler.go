@@ -6,26 +6,32 @@ import (
 
 	"github.com/ayunami2000/ayunsdcord/commands/command"
 	"github.com/ayunami2000/ayunsdcord/config"
+	"github.com/ayunami2000/ayunsdcord/sdapi"
 	"github.com/ayunami2000/ayunsdcord/utils"
 )
 
-var VALID_UPSCALERS = []string{"RealESRGAN_x4plus", "RealESRGAN_x4plus_anime_6B"}
-var UpscalerCommand = command.NewCommand("upscaler", []string{"u"}, upscalerRun)
+var UpscalerCommand = command.NewCommand("upscaler", []string{"u"}, upscalerRun).Describe("Shows or sets the upscaler",
+	command.Option{Name: "name", Description: "Upscaler to use", Type: command.StringOption, Autocomplete: sdapi.GetUpscalers})
 var ErrInvalidUpscaler = errors.New("invalid upscaler")
 
 func upscalerRun(cmdctx *command.CommandContext) error {
+	upscalers, err := sdapi.GetUpscalers()
+	if err != nil {
+		return err
+	}
+
 	if cmdctx.Args == "" {
 		_, err := cmdctx.TryReply(`**Current upscaler:** %s
-Upscalers: %s`, utils.StringOrNone(cmdctx.ChannelSettings.Upscaler), strings.Join(VALID_UPSCALERS, ", "))
+Upscalers: %s`, utils.StringOrNone(cmdctx.ChannelSettings.Upscaler), strings.Join(upscalers, ", "))
 		return err
 	}
 
-	if err := config.CanChange(cmdctx.ChannelSettings.InUse, "upscaler"); err != nil {
+	if err := config.CanChange("upscaler"); err != nil {
 		return err
 	}
 
 	upscaler := ""
-	for _, u := range VALID_UPSCALERS {
+	for _, u := range upscalers {
 		if strings.EqualFold(u, cmdctx.Args) {
 			upscaler = u
 			break
@@ -38,6 +44,6 @@ Upscalers: %s`, utils.StringOrNone(cmdctx.ChannelSettings.Upscaler), strings.Joi
 
 	cmdctx.ChannelSettings.Upscaler = upscaler
 
-	_, err := cmdctx.TryReply("**Upscaler set to:** %s", upscaler)
+	_, err = cmdctx.TryReply("**Upscaler set to:** %s", upscaler)
 	return err
 }
@@ -10,7 +10,8 @@ import (
 	"github.com/ayunami2000/ayunsdcord/utils"
 )
 
-var VaeCommand = command.NewCommand("vae", []string{"v"}, vaeCommandRun)
+var VaeCommand = command.NewCommand("vae", []string{"v"}, vaeCommandRun).Describe("Shows or sets the VAE",
+	command.Option{Name: "name", Description: "VAE to use", Type: command.StringOption, Autocomplete: vaeNames})
 var ErrInvalidVae = errors.New("invalid vae")
 
 func vaeCommandRun(cmdctx *command.CommandContext) error {
@@ -19,7 +20,7 @@ func vaeCommandRun(cmdctx *command.CommandContext) error {
 		return err
 	}
 
-	if err := config.CanChange(cmdctx.ChannelSettings.InUse, "vae"); err != nil {
+	if err := config.CanChange("vae"); err != nil {
 		return err
 	}
 
@@ -0,0 +1,34 @@
+package commands
+
+import (
+	"math"
+	"strconv"
+
+	"github.com/ayunami2000/ayunsdcord/commands/command"
+	"github.com/ayunami2000/ayunsdcord/commands/render"
+)
+
+var VariationCommand = command.NewCommand("variation", []string{"va"}, variationRun).Describe("Renders variants of the last render, or the render replied to, with the same seed",
+	command.Option{Name: "strength", Description: "How much the variants differ, from 0 to 1", Type: command.NumberOption})
+
+// How many variants are rendered at once
+const variationCount = 4
+
+func variationRun(cmdctx *command.CommandContext) error {
+	strength := render.VariationStrength
+	if cmdctx.Args != "" {
+		f, err := strconv.ParseFloat(cmdctx.Args, 64)
+		if err != nil {
+			return err
+		}
+
+		strength = math.Min(math.Max(f, 0.01), 1)
+	}
+
+	record, err := render.TargetRecord(cmdctx)
+	if err != nil {
+		return err
+	}
+
+	return render.Variation(cmdctx, record, 0, strength, variationCount)
+}
@@ -0,0 +1,55 @@
+package commands
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ayunami2000/ayunsdcord/commands/command"
+	"github.com/ayunami2000/ayunsdcord/commands/render"
+	"github.com/ayunami2000/ayunsdcord/utils"
+)
+
+var WildcardsCommand = command.NewCommand("wildcards", []string{"wc"}, wildcardsRun).Describe("Lists the wildcard files or previews what a wildcard expands to",
+	command.Option{Name: "wildcard", Description: "Wildcard name, or a whole prompt using wildcards", Type: command.StringOption, Autocomplete: render.ListWildcards})
+
+// How many expansions a preview shows
+const wildcardSamples = 3
+
+func wildcardsRun(cmdctx *command.CommandContext) error {
+	if cmdctx.Args == "" {
+		names, err := render.ListWildcards()
+		if err != nil {
+			return err
+		}
+
+		wildcards := []string{}
+		for _, name := range names {
+			wildcards = append(wildcards, "__"+name+"__")
+		}
+
+		_, err = cmdctx.TryReply("**Wildcards:** %s", utils.StringOrNone(strings.Join(wildcards, ", ")))
+		return err
+	}
+
+	prompt := cmdctx.Args
+	if !render.HasWildcards(prompt) {
+		// A bare name, which can't be a wildcard if it isn't one once wrapped
+		prompt = "__" + prompt + "__"
+		if !render.IsWildcard(prompt) {
+			return render.ErrUnknownWildcard
+		}
+	}
+
+	content := fmt.Sprintf("**Preview of:** %s", prompt)
+	for i := 0; i < wildcardSamples; i++ {
+		expanded, err := render.ExpandWildcards(prompt)
+		if err != nil {
+			return err
+		}
+
+		content += "\n- " + expanded
+	}
+
+	_, err := cmdctx.TryReply("%s", content)
+	return err
+}
@@ -0,0 +1,260 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/ayunami2000/ayunsdcord/commands/command"
+	"github.com/ayunami2000/ayunsdcord/commands/render"
+	"github.com/ayunami2000/ayunsdcord/config"
+	"github.com/ayunami2000/ayunsdcord/sdapi"
+	"github.com/ayunami2000/ayunsdcord/utils"
+)
+
+var XYCommand = command.NewCommand("xy", []string{"plot"}, xyRun).Describe("Renders a grid comparing settings, e.g. steps=20,30,40 cfg=5,7,12",
+	command.Option{Name: "x", Description: "Setting and values of the columns, e.g. steps=20,30,40", Type: command.StringOption, Required: true},
+	command.Option{Name: "y", Description: "Setting and values of the rows, e.g. cfg=5,7,12", Type: command.StringOption})
+var ErrInvalidAxis = errors.New("invalid axis, use setting=value1,value2 and quote values with commas in them")
+var ErrTooManyAxes = errors.New("at most two axes are allowed")
+var ErrTooManyCells = fmt.Errorf("at most %d renders are allowed in a plot", maxXYCells)
+
+// Every render of a plot is queued at once, so keep them from filling the queue
+const maxXYCells = 25
+
+// Start of each "setting=" in the arguments, values can contain spaces
+var axisRegex = regexp.MustCompile(`(?:^|\s)([A-Za-z]+)=`)
+
+// Other names for the properties of clear
+var axisAliases = map[string]string{
+	"steps":   "inferencesteps",
+	"cfg":     "guidancescale",
+	"denoise": "promptstrength",
+	"m":       "model",
+	"sm":      "sampler",
+}
+
+// Looks up one of the names of the backend, none is allowed to turn it off
+func findName(value string, names func() ([]string, error)) (string, error) {
+	if strings.EqualFold(value, "none") {
+		return "", nil
+	}
+
+	options, err := names()
+	if err != nil {
+		return "", err
+	}
+
+	name := findFold(options, value)
+	if name == "" {
+		return "", ErrNotAvailable
+	}
+
+	return name, nil
+}
+
+// Applies one value of an axis to the settings of a cell, by the property names of clear
+var axisSetters = map[string]func(settings *command.ChannelSettings, value string) error{
+	"prompt": func(settings *command.ChannelSettings, value string) error {
+		settings.Prompt = utils.TruncateText(value, 512)
+		return nil
+	},
+	"negativeprompt": func(settings *command.ChannelSettings, value string) error {
+		settings.NegativePrompt = utils.TruncateText(value, 512)
+		return nil
+	},
+	"promptstrength": func(settings *command.ChannelSettings, value string) error {
+		f, err := strconv.ParseFloat(value, 64)
+		settings.PromptStrength = math.Min(math.Max(f, 0), 0.999_999)
+		return err
+	},
+	"inferencesteps": importers["Steps"],
+	"guidancescale":  importers["CFG scale"],
+	"sampler":        importers["Sampler"],
+	"seed":           importers["Seed"],
+	"size":           importers["Size"],
+	"model":          importers["Model"],
+	"width": func(settings *command.ChannelSettings, value string) (err error) {
+		settings.Width, err = parseSize(value)
+		return err
+	},
+	"height": func(settings *command.ChannelSettings, value string) (err error) {
+		settings.Height, err = parseSize(value)
+		return err
+	},
+	"vae": func(settings *command.ChannelSettings, value string) (err error) {
+		settings.VAE, err = findName(value, vaeNames)
+		return err
+	},
+	"hypernetwork": func(settings *command.ChannelSettings, value string) (err error) {
+		settings.HyperNetwork, err = findName(value, hyperNetworkNames)
+		return err
+	},
+	"upscaler": func(settings *command.ChannelSettings, value string) (err error) {
+		settings.Upscaler, err = findName(value, sdapi.GetUpscalers)
+		return err
+	},
+	"upscaleamount": func(settings *command.ChannelSettings, value string) error {
+		i, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			return err
+		} else if !utils.Contains(VALID_UPSCALE_AMOUNTS, uint(i)) {
+			return ErrInvalidUpscaleAmount
+		}
+
+		settings.UpscaleAmount = uint(i)
+		return nil
+	},
+	"controlnet": func(settings *command.ChannelSettings, value string) (err error) {
+		settings.ControlNet, err = findName(value, controlNetNames)
+		return err
+	},
+	"controlnetweight": func(settings *command.ChannelSettings, value string) error {
+		f, err := strconv.ParseFloat(value, 64)
+		settings.ControlNetWeight = math.Min(math.Max(f, 0), 2)
+		return err
+	},
+}
+
+func axisProperty(name string) string {
+	name = strings.ToLower(name)
+	if property, exists := axisAliases[name]; exists {
+		return property
+	} else if property, exists := chgMap[name]; exists {
+		return property
+	}
+
+	return name
+}
+
+// Whether the position is between double quotes
+func quotedAt(text string, pos int) bool {
+	return strings.Count(text[:pos], "\"")%2 == 1
+}
+
+// Splits the values of an axis on commas, values in double quotes can contain commas and "setting=" themselves
+func splitAxisValues(text string) ([]string, error) {
+	if strings.Count(text, "\"")%2 == 1 {
+		return nil, ErrInvalidAxis
+	}
+
+	values := []string{}
+	last := 0
+	for i := 0; i <= len(text); i++ {
+		if i < len(text) && (text[i] != ',' || quotedAt(text, i)) {
+			continue
+		}
+
+		value := strings.TrimSpace(text[last:i])
+		if len(value) >= 2 && strings.HasPrefix(value, "\"") && strings.HasSuffix(value, "\"") {
+			value = value[1 : len(value)-1]
+		}
+		if value != "" {
+			values = append(values, value)
+		}
+
+		last = i + 1
+	}
+
+	return values, nil
+}
+
+func parseAxes(args string) ([]render.Axis, error) {
+	matches := [][]int{}
+	for _, match := range axisRegex.FindAllStringSubmatchIndex(args, -1) {
+		if !quotedAt(args, match[0]) {
+			matches = append(matches, match)
+		}
+	}
+
+	if len(matches) == 0 || strings.TrimSpace(args[:matches[0][0]]) != "" {
+		return nil, ErrInvalidAxis
+	} else if len(matches) > 2 {
+		return nil, ErrTooManyAxes
+	}
+
+	axes := []render.Axis{}
+	for i, match := range matches {
+		end := len(args)
+		if i+1 < len(matches) {
+			end = matches[i+1][0]
+		}
+
+		axis := render.Axis{Name: axisProperty(args[match[2]:match[3]])}
+		if _, exists := axisSetters[axis.Name]; !exists {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidProperty, args[match[2]:match[3]])
+		}
+
+		values, err := splitAxisValues(args[match[1]:end])
+		if err != nil {
+			return nil, err
+		}
+
+		axis.Values = values
+		if len(axis.Values) == 0 {
+			return nil, ErrInvalidAxis
+		}
+
+		axes = append(axes, axis)
+	}
+
+	return axes, nil
+}
+
+// Settings of one cell of the plot, every cell has the same seed unless it is an axis
+func cellSettings(settings command.ChannelSettings, axes []render.Axis, values []string) (*command.ChannelSettings, error) {
+	for i, axis := range axes {
+		if err := config.CanChange(axis.Name); err != nil {
+			return nil, err
+		}
+
+		if err := axisSetters[axis.Name](&settings, values[i]); err != nil {
+			return nil, fmt.Errorf("%s=%s: %w", axis.Name, values[i], err)
+		}
+	}
+
+	return &settings, nil
+}
+
+func xyRun(cmdctx *command.CommandContext) error {
+	axes, err := parseAxes(cmdctx.Args)
+	if err != nil {
+		return err
+	}
+
+	x := axes[0]
+	y := render.Axis{}
+	yValues := []string{""}
+	if len(axes) > 1 {
+		y = axes[1]
+		yValues = y.Values
+	}
+
+	if len(x.Values)*len(yValues) > maxXYCells {
+		return ErrTooManyCells
+	}
+
+	base := *cmdctx.ChannelSettings
+	if base.Seed < 0 {
+		base.Seed = int(rand.Int31())
+	}
+	base.BatchSize = 1
+
+	cells := []*sdapi.RenderData{}
+	for _, yValue := range yValues {
+		for _, xValue := range x.Values {
+			settings, err := cellSettings(base, axes, []string{xValue, yValue})
+			if err != nil {
+				return err
+			}
+
+			cells = append(cells, render.NewRenderData(settings))
+		}
+	}
+
+	return render.RenderXY(cmdctx, cells, x, y)
+}
@@ -6,7 +6,6 @@ import (
 	"strconv"
 	"strings"
 	"sync"
-	"sync/atomic"
 
 	"github.com/diamondburned/arikawa/v3/discord"
 	"github.com/fsnotify/fsnotify"
@@ -14,7 +13,6 @@ import (
 )
 
 var ErrCannotChangeProperty = errors.New("not allowed to change property")
-var ErrPropertyLocked = errors.New("locked while rendering")
 var imageDumpChannelId = discord.NullChannelID
 
 type UsersList struct {
@@ -22,25 +20,48 @@ type UsersList struct {
 	List          []string
 }
 
+type BackendConfig struct {
+	Backend     string
+	URL         string
+	BasicAuth   string
+	Weight      uint
+	Concurrency uint
+}
+
 type configStruct struct {
 	BotToken           string
 	ChannelIds         []string
 	ImageDumpChannelId string
 	Prefix             string
+	SlashCommands      bool
 	AllowBots          bool
 
+	Backend             string
 	StableDiffusionURL  string
 	BasicAuth           string
+	Backends            []BackendConfig
+	HealthCheckInterval uint
+	RenderConcurrency   uint
 	StreamImageProgress uint
-
-	FrameUrl        string
-	FrameHttpBind   string
-	CountFrameless  bool
-	LoadingFrameUrl string
-	ErrorFrameUrl   string
+	ComfyWorkflow       string
+
+	FrameUrl      string
+	FrameHttpBind string
+	// Serves a gallery of finished renders on FrameHttpBind
+	Gallery bool
+	// Only renders from these servers and channels are in the gallery
+	GalleryGuildIds   []string
+	GalleryChannelIds []string
+	CountFrameless    bool
+	LoadingFrameUrl   string
+	ErrorFrameUrl     string
+	// In MiB, larger results are re-encoded or downscaled before being uploaded
+	MaxUploadSize uint
 
 	DefaultPrompt         string
 	DefaultNegativePrompt string
+	WildcardsDir          string
+	MaxPromptCombinations uint
 
 	DefaultWidth  uint
 	DefaultHeight uint
@@ -52,6 +73,9 @@ type configStruct struct {
 	DefaultUpscaler       string
 	DefaultUpscaleAmount  uint
 
+	DatabasePath       string
+	ChannelSettingsTTL uint
+
 	DenyChanging []string
 	UsersList    UsersList
 
@@ -72,20 +96,32 @@ func init() {
 	viper.AutomaticEnv()
 
 	viper.SetDefault("Prefix", "sd!")
+	viper.SetDefault("SlashCommands", true)
 	viper.SetDefault("ChannelIds", []string{})
 	viper.SetDefault("AllowBots", false)
 
+	viper.SetDefault("Backend", "easydiffusion")
 	viper.SetDefault("StableDiffusionURL", "http://localhost:9000")
+	viper.SetDefault("Backends", []BackendConfig{})
+	viper.SetDefault("HealthCheckInterval", 30)
+	viper.SetDefault("RenderConcurrency", 1)
 	viper.SetDefault("StreamImageProgress", 5)
+	viper.SetDefault("ComfyWorkflow", "workflow.json")
 	viper.SetDefault("CountFrameless", false)
+	viper.SetDefault("MaxUploadSize", 10)
 
 	viper.SetDefault("FrameHttpBind", ":8080")
 	viper.SetDefault("FrameUrl", "")
+	viper.SetDefault("Gallery", false)
+	viper.SetDefault("GalleryGuildIds", []string{})
+	viper.SetDefault("GalleryChannelIds", []string{})
 	viper.SetDefault("LoadingFrameUrl", "https://c.tenor.com/RVvnVPK-6dcAAAAC/reload-cat.gif")
 	viper.SetDefault("ErrorFrameUrl", "https://upload.wikimedia.org/wikipedia/commons/f/f7/Generic_error_message.png")
 
 	viper.SetDefault("DefaultPrompt", "cat")
 	viper.SetDefault("DefaultNegativePrompt", "nsfw")
+	viper.SetDefault("WildcardsDir", "wildcards")
+	viper.SetDefault("MaxPromptCombinations", 9)
 
 	viper.SetDefault("DefaultWidth", 768)
 	viper.SetDefault("DefaultHeight", 768)
@@ -96,6 +132,9 @@ func init() {
 	viper.SetDefault("DefaultSampler", "euler_a")
 	viper.SetDefault("DefaultUpscaleAmount", 2)
 
+	viper.SetDefault("DatabasePath", "ayunsdcord.db")
+	viper.SetDefault("ChannelSettingsTTL", 20)
+
 	viper.SetDefault("DenyChanging", []string{})
 	viper.SetDefault("UsersList.WhitelistMode", false)
 	viper.SetDefault("UsersList.List", []string{})
@@ -151,26 +190,14 @@ func GetImageDumpChannelId() discord.ChannelID {
 	return imageDumpChannelId
 }
 
-func CanChange_NoLock(s string) bool {
+func CanChange(s string) error {
 	ConfigMutex.Lock()
 	defer ConfigMutex.Unlock()
 	for _, v := range Config.DenyChanging {
 		if strings.EqualFold(strings.ReplaceAll(v, "_", ""), s) {
-			return false
+			return ErrCannotChangeProperty
 		}
 	}
 
-	return true
-}
-
-func CanChange(b *atomic.Bool, s string) error {
-	if !CanChange_NoLock(s) {
-		return ErrCannotChangeProperty
-	}
-
-	if b.Load() {
-		return ErrPropertyLocked
-	}
-
 	return nil
 }
@@ -0,0 +1,256 @@
+package gallery
+
+import (
+	"encoding/json"
+	"errors"
+	"html/template"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ayunami2000/ayunsdcord/commands/render"
+	"github.com/ayunami2000/ayunsdcord/config"
+	"github.com/ayunami2000/ayunsdcord/sdapi"
+	"github.com/ayunami2000/ayunsdcord/utils"
+	"github.com/diamondburned/arikawa/v3/discord"
+)
+
+const pageSize = 24
+
+// A finished render as the gallery shows it, without the images it was made from
+type Render struct {
+	ID          discord.MessageID `json:"id"`
+	RequestedBy discord.UserID    `json:"requested_by"`
+	GuildID     discord.GuildID   `json:"guild_id"`
+	ChannelID   discord.ChannelID `json:"channel_id"`
+	MessageURL  string            `json:"message_url"`
+	Started     time.Time         `json:"started"`
+	// In seconds
+	Duration   float64          `json:"duration"`
+	Images     []string         `json:"images"`
+	Parameters string           `json:"parameters"`
+	Data       sdapi.RenderData `json:"data"`
+}
+
+func newRender(entry *render.HistoryEntry) *Render {
+	data := entry.Data
+	// Data URLs of input images are large and not meant to be shared
+	data.InitImage = ""
+	data.Mask = ""
+	data.ControlImage = ""
+	data.VariationImage = ""
+	data.SessionId = ""
+
+	return &Render{
+		ID:          entry.MessageID,
+		RequestedBy: entry.RequestedBy,
+		GuildID:     entry.GuildID,
+		ChannelID:   entry.ChannelID,
+		MessageURL:  entry.URL(),
+		Started:     entry.Started,
+		Duration:    entry.Duration.Seconds(),
+		Images:      entry.Images(),
+		Parameters:  sdapi.FormatParameters(&data, 0),
+		Data:        data,
+	}
+}
+
+// Only renders that finished in a server or channel the gallery is enabled for are shown, what happens in DMs stays there
+func shown(entry *render.HistoryEntry) bool {
+	if entry.Outcome != render.OutcomeSucceeded || !entry.GuildID.IsValid() || len(entry.ImageURLs) == 0 {
+		return false
+	}
+
+	config.ConfigMutex.Lock()
+	defer config.ConfigMutex.Unlock()
+
+	return utils.Contains(config.Config.GalleryGuildIds, entry.GuildID.String()) || utils.Contains(config.Config.GalleryChannelIds, entry.ChannelID.String())
+}
+
+type filter struct {
+	User    discord.UserID
+	Model   string
+	Channel discord.ChannelID
+	// Counting from 1
+	Page int
+}
+
+func parseFilter(r *http.Request) filter {
+	query := r.URL.Query()
+	f := filter{Model: strings.TrimSpace(query.Get("model")), Page: 1}
+	if id, err := discord.ParseSnowflake(query.Get("user")); err == nil {
+		f.User = discord.UserID(id)
+	}
+	if id, err := discord.ParseSnowflake(query.Get("channel")); err == nil {
+		f.Channel = discord.ChannelID(id)
+	}
+	if page, err := strconv.Atoi(query.Get("page")); err == nil && page > 1 {
+		f.Page = page
+	}
+
+	return f
+}
+
+func (f filter) match(entry *render.HistoryEntry) bool {
+	return shown(entry) &&
+		(!f.User.IsValid() || entry.RequestedBy == f.User) &&
+		(f.Model == "" || strings.EqualFold(entry.Data.UseStableDiffusionModel, f.Model)) &&
+		(!f.Channel.IsValid() || entry.ChannelID == f.Channel)
+}
+
+// Query string of the filter on another page
+func (f filter) query(page int) string {
+	values := url.Values{}
+	if f.User.IsValid() {
+		values.Set("user", f.User.String())
+	}
+	if f.Model != "" {
+		values.Set("model", f.Model)
+	}
+	if f.Channel.IsValid() {
+		values.Set("channel", f.Channel.String())
+	}
+	if page > 1 {
+		values.Set("page", strconv.Itoa(page))
+	}
+
+	if len(values) == 0 {
+		return ""
+	}
+
+	return "?" + values.Encode()
+}
+
+// One page of renders matching the filter, and whether there are more after it
+func (f filter) renders() ([]*Render, bool, error) {
+	entries, err := render.QueryHistory(f.match, (f.Page-1)*pageSize, pageSize+1)
+	if err != nil {
+		return nil, false, err
+	}
+
+	more := len(entries) > pageSize
+	if more {
+		entries = entries[:pageSize]
+	}
+
+	renders := []*Render{}
+	for i := range entries {
+		renders = append(renders, newRender(&entries[i]))
+	}
+
+	return renders, more, nil
+}
+
+func findRender(path string, prefix string) (*Render, error) {
+	id, err := discord.ParseSnowflake(strings.Trim(strings.TrimPrefix(path, prefix), "/"))
+	if err != nil {
+		return nil, render.ErrRecordNotFound
+	}
+
+	entry, err := render.GetHistoryEntry(discord.MessageID(id))
+	if err != nil {
+		return nil, err
+	} else if !shown(entry) {
+		return nil, render.ErrRecordNotFound
+	}
+
+	return newRender(entry), nil
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	if errors.Is(err, render.ErrRecordNotFound) {
+		http.Error(w, "render not found", http.StatusNotFound)
+		return
+	}
+
+	log.Println("Could not serve gallery:", err)
+	http.Error(w, "internal error", http.StatusInternalServerError)
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeHTML(w http.ResponseWriter, tmpl *template.Template, v any) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := tmpl.Execute(w, v); err != nil {
+		log.Println("Could not render gallery page:", err)
+	}
+}
+
+func listPage(w http.ResponseWriter, r *http.Request) {
+	f := parseFilter(r)
+	renders, more, err := f.renders()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	page := struct {
+		Filter  filter
+		Renders []*Render
+		Prev    string
+		Next    string
+	}{Filter: f, Renders: renders}
+
+	if f.Page > 1 {
+		page.Prev = "/gallery" + f.query(f.Page-1)
+	}
+	if more {
+		page.Next = "/gallery" + f.query(f.Page+1)
+	}
+
+	writeHTML(w, listTemplate, page)
+}
+
+func detailPage(w http.ResponseWriter, r *http.Request) {
+	if strings.Trim(r.URL.Path, "/") == "gallery" {
+		listPage(w, r)
+		return
+	}
+
+	rnd, err := findRender(r.URL.Path, "/gallery/")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeHTML(w, detailTemplate, rnd)
+}
+
+func listJSON(w http.ResponseWriter, r *http.Request) {
+	f := parseFilter(r)
+	renders, more, err := f.renders()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, struct {
+		Page    int       `json:"page"`
+		More    bool      `json:"more"`
+		Renders []*Render `json:"renders"`
+	}{f.Page, more, renders})
+}
+
+func detailJSON(w http.ResponseWriter, r *http.Request) {
+	rnd, err := findRender(r.URL.Path, "/api/renders/")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, rnd)
+}
+
+// Adds the gallery pages and their JSON counterparts to the mux
+func Register(mux *http.ServeMux) {
+	mux.HandleFunc("/gallery", listPage)
+	mux.HandleFunc("/gallery/", detailPage)
+	mux.HandleFunc("/api/renders", listJSON)
+	mux.HandleFunc("/api/renders/", detailJSON)
+}
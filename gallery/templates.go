@@ -0,0 +1,68 @@
+package gallery
+
+import (
+	"html/template"
+	"strconv"
+)
+
+const style = `<style>
+body { font-family: sans-serif; background: #1e1f22; color: #dbdee1; margin: 2em; }
+a { color: #00a8fc; }
+form input { margin-right: .5em; }
+.grid { display: grid; grid-template-columns: repeat(auto-fill, minmax(200px, 1fr)); gap: 1em; margin: 1em 0; }
+.grid img { width: 100%; aspect-ratio: 1; object-fit: cover; border-radius: 4px; }
+.grid p { margin: .25em 0; font-size: .85em; overflow: hidden; text-overflow: ellipsis; white-space: nowrap; }
+.images img { max-width: 100%; margin-bottom: 1em; }
+th { text-align: left; padding-right: 1em; }
+pre { white-space: pre-wrap; background: #2b2d31; padding: 1em; border-radius: 4px; }
+</style>`
+
+var funcs = template.FuncMap{
+	"seconds": func(f float64) string { return strconv.FormatFloat(f, 'f', 1, 64) },
+}
+
+var listTemplate = template.Must(template.New("list").Funcs(funcs).Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Gallery</title>` + style + `</head>
+<body>
+<h1><a href="/gallery">Gallery</a></h1>
+<form action="/gallery">
+<input name="user" placeholder="User ID" value="{{if .Filter.User.IsValid}}{{.Filter.User}}{{end}}">
+<input name="model" placeholder="Model" value="{{.Filter.Model}}">
+<input name="channel" placeholder="Channel ID" value="{{if .Filter.Channel.IsValid}}{{.Filter.Channel}}{{end}}">
+<button>Filter</button>
+</form>
+<div class="grid">
+{{range .Renders}}<a href="/gallery/{{.ID}}"><img src="{{index .Images 0}}" loading="lazy" alt="" title="{{.Data.Prompt}}"><p>{{.Data.Prompt}}</p></a>
+{{else}}<p>No renders found.</p>
+{{end}}</div>
+<p>{{if .Prev}}<a href="{{.Prev}}">&larr; Newer</a>{{end}} Page {{.Filter.Page}} {{if .Next}}<a href="{{.Next}}">Older &rarr;</a>{{end}}</p>
+</body>
+</html>`))
+
+var detailTemplate = template.Must(template.New("detail").Funcs(funcs).Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>{{.Data.Prompt}}</title>` + style + `</head>
+<body>
+<h1><a href="/gallery">Gallery</a></h1>
+<div class="images">{{range .Images}}<a href="{{.}}"><img src="{{.}}" alt=""></a>
+{{end}}</div>
+<table>
+<tr><th>Prompt</th><td>{{.Data.Prompt}}</td></tr>
+{{if .Data.NegativePrompt}}<tr><th>Negative prompt</th><td>{{.Data.NegativePrompt}}</td></tr>{{end}}
+<tr><th>Model</th><td><a href="/gallery?model={{.Data.UseStableDiffusionModel}}">{{.Data.UseStableDiffusionModel}}</a></td></tr>
+<tr><th>Seed</th><td>{{.Data.Seed}}</td></tr>
+<tr><th>Size</th><td>{{.Data.Width}}x{{.Data.Height}}</td></tr>
+<tr><th>Steps</th><td>{{.Data.NumInferenceSteps}}</td></tr>
+<tr><th>Guidance scale</th><td>{{.Data.GuidanceScale}}</td></tr>
+<tr><th>Sampler</th><td>{{.Data.SamplerName}}</td></tr>
+<tr><th>Requested by</th><td><a href="/gallery?user={{.RequestedBy}}">{{.RequestedBy}}</a></td></tr>
+<tr><th>Channel</th><td><a href="/gallery?channel={{.ChannelID}}">{{.ChannelID}}</a></td></tr>
+<tr><th>Started</th><td>{{.Started.Format "2006-01-02 15:04:05 MST"}}</td></tr>
+<tr><th>Duration</th><td>{{seconds .Duration}}s</td></tr>
+</table>
+<h2>Parameters</h2>
+<pre>{{.Parameters}}</pre>
+<p><a href="{{.MessageURL}}">View on Discord</a> &middot; <a href="/api/renders/{{.ID}}">JSON</a></p>
+</body>
+</html>`))
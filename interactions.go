@@ -0,0 +1,207 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/ayunami2000/ayunsdcord/commands"
+	"github.com/ayunami2000/ayunsdcord/commands/command"
+	"github.com/ayunami2000/ayunsdcord/commands/render"
+	"github.com/ayunami2000/ayunsdcord/config"
+
+	"github.com/diamondburned/arikawa/v3/api"
+	"github.com/diamondburned/arikawa/v3/discord"
+	"github.com/diamondburned/arikawa/v3/gateway"
+	"github.com/diamondburned/arikawa/v3/utils/json/option"
+)
+
+// Replaces the registered slash commands, or removes them all if they are disabled
+func registerSlashCommands() error {
+	config.ConfigMutex.Lock()
+	slashCommands := config.Config.SlashCommands
+	config.ConfigMutex.Unlock()
+
+	app, err := s.CurrentApplication()
+	if err != nil {
+		return err
+	}
+
+	data := []api.CreateCommandData{}
+	if slashCommands {
+		for _, cmd := range executor.GetCommands() {
+			data = append(data, cmd.CreateCommandData())
+		}
+	}
+
+	_, err = s.BulkOverwriteCommands(app.ID, data)
+	return err
+}
+
+func interactionCreate(e *gateway.InteractionCreateEvent) {
+	switch data := e.Data.(type) {
+	case *discord.CommandInteraction:
+		slashCommand(&e.InteractionEvent, data)
+	case *discord.AutocompleteInteraction:
+		autocomplete(&e.InteractionEvent, data)
+	case *discord.ButtonInteraction:
+		if e.Message != nil && strings.HasPrefix(string(data.CustomID), render.ButtonPrefix) {
+			renderButton(&e.InteractionEvent, data)
+		} else if e.Message != nil && strings.HasPrefix(string(data.CustomID), commands.HistoryButtonPrefix) {
+			historyButton(&e.InteractionEvent, data)
+		}
+	}
+}
+
+func respondEphemeral(e *discord.InteractionEvent, content string) {
+	_ = s.RespondInteraction(e.ID, e.Token, api.InteractionResponse{
+		Type: api.MessageInteractionWithSource,
+		Data: &api.InteractionResponseData{
+			Content: option.NewNullableString(content),
+			Flags:   discord.EphemeralMessage,
+		},
+	})
+}
+
+// Checks the sender and defers the response, returns nil if the interaction was already answered
+func startInteraction(e *discord.InteractionEvent) *command.CommandContext {
+	sender := e.Sender()
+	if sender == nil || !isAllowed(e.ChannelID, sender) {
+		respondEphemeral(e, "**Error:** You are not allowed to use this bot here.")
+		return nil
+	}
+
+	unlock := lockChannelSettings(e.ChannelID)
+	settings, err := getChannelSettings(e.ChannelID)
+	if err != nil {
+		unlock()
+		respondEphemeral(e, fmt.Sprintf("**Error:** %v", err))
+		log.Println("Could not query app config:", err)
+		return nil
+	}
+
+	// Renders take far longer than the three seconds Discord waits for a response
+	err = s.RespondInteraction(e.ID, e.Token, api.InteractionResponse{Type: api.DeferredMessageInteractionWithSource})
+	if err != nil {
+		unlock()
+		log.Println("Could not respond to interaction:", err)
+		return nil
+	}
+
+	return &command.CommandContext{
+		Executor:        executor,
+		ChannelSettings: settings,
+		Message: &discord.Message{
+			ChannelID: e.ChannelID,
+			GuildID:   e.GuildID,
+			Author:    *sender,
+		},
+		CalledWithPrefix: "/",
+		// The deferred response already shows that the bot is working on it
+		StopTyping:      make(chan struct{}, 1),
+		Interaction:     e,
+		ReleaseSettings: releaseChannelSettings(e.ChannelID, settings, unlock),
+	}
+}
+
+// Reports the error of the command and removes the deferred response if nothing was sent
+func finishInteraction(context *command.CommandContext, err error) {
+	if err != nil {
+		str := err.Error()
+		_, _ = context.TryReply("**Error:** %s.", strings.ToUpper(str[:1])+str[1:])
+	}
+
+	if !context.Responded() {
+		_ = s.DeleteInteractionResponse(context.Interaction.AppID, context.Interaction.Token)
+	}
+}
+
+func slashCommand(e *discord.InteractionEvent, data *discord.CommandInteraction) {
+	cmd := executor.FindCommand(data.Name)
+	if cmd == nil {
+		respondEphemeral(e, "**Error:** Command not found.")
+		return
+	}
+
+	context := startInteraction(e)
+	if context == nil {
+		return
+	}
+
+	defer context.DoneWithSettings()
+
+	args := []string{}
+	for _, opt := range cmd.Options {
+		value := data.Options.Find(opt.Name)
+		if value.Name == "" {
+			continue
+		}
+
+		if opt.Type == command.AttachmentOption {
+			id, err := value.SnowflakeValue()
+			if attachment, exists := data.Resolved.Attachments[discord.AttachmentID(id)]; err == nil && exists {
+				context.Message.Attachments = append(context.Message.Attachments, attachment)
+			}
+
+			continue
+		}
+
+		args = append(args, strings.TrimSpace(value.String()))
+	}
+
+	context.CalledWithAlias = cmd.Name
+	context.Args = strings.Replace(strings.Join(args, " "), "\n", " ", -1)
+
+	finishInteraction(context, executor.RunCommand(cmd.Name, context))
+}
+
+func renderButton(e *discord.InteractionEvent, data *discord.ButtonInteraction) {
+	context := startInteraction(e)
+	if context == nil {
+		return
+	}
+
+	defer context.DoneWithSettings()
+
+	context.CalledWithAlias = render.RenderCommand.Name
+	finishInteraction(context, render.Button(context, e.Message.ID, string(data.CustomID)))
+}
+
+// Turns the page of a history embed in place
+func historyButton(e *discord.InteractionEvent, data *discord.ButtonInteraction) {
+	sender := e.Sender()
+	if sender == nil || !isAllowed(e.ChannelID, sender) {
+		respondEphemeral(e, "**Error:** You are not allowed to use this bot here.")
+		return
+	}
+
+	embed, components, err := commands.HistoryButton(sender.ID, e.GuildID, string(data.CustomID))
+	if err != nil {
+		respondEphemeral(e, fmt.Sprintf("**Error:** %v", err))
+		return
+	}
+
+	_ = s.RespondInteraction(e.ID, e.Token, api.InteractionResponse{
+		Type: api.UpdateMessage,
+		Data: &api.InteractionResponseData{
+			Embeds:     &[]discord.Embed{embed},
+			Components: components,
+		},
+	})
+}
+
+func autocomplete(e *discord.InteractionEvent, data *discord.AutocompleteInteraction) {
+	choices := api.AutocompleteStringChoices{}
+	sender := e.Sender()
+	if cmd := executor.FindCommand(data.Name); cmd != nil && sender != nil && isAllowed(e.ChannelID, sender) {
+		focused := data.Options.Focused()
+		if opt := cmd.Option(focused.Name); opt != nil {
+			choices = opt.Complete(focused.String())
+		}
+	}
+
+	_ = s.RespondInteraction(e.ID, e.Token, api.InteractionResponse{
+		Type: api.AutocompleteResult,
+		Data: &api.InteractionResponseData{Choices: choices},
+	})
+}
@@ -6,16 +6,15 @@ import (
 	"log"
 	"math/rand"
 	"net/http"
-	"strconv"
 	"strings"
-	"sync/atomic"
 	"time"
 
 	"github.com/ayunami2000/ayunsdcord/commands"
 	"github.com/ayunami2000/ayunsdcord/commands/command"
 	"github.com/ayunami2000/ayunsdcord/commands/render"
 	"github.com/ayunami2000/ayunsdcord/config"
-	"github.com/ayunami2000/ayunsdcord/sdapi"
+	"github.com/ayunami2000/ayunsdcord/gallery"
+	"github.com/ayunami2000/ayunsdcord/store"
 	"github.com/ayunami2000/ayunsdcord/utils"
 
 	"github.com/diamondburned/arikawa/v3/discord"
@@ -38,27 +37,31 @@ func canUse(authorId string) bool {
 var s *state.State
 var botID discord.UserID
 var executor *command.Executor
-var channels = utils.NewForgetfulMap[string, *command.ChannelSettings](20 * time.Minute)
+var channels = utils.NewForgetfulMap[string, *command.ChannelSettings](time.Duration(config.Config.ChannelSettingsTTL) * time.Minute)
 
-func messageCreate(c *gateway.MessageCreateEvent) {
-	if c.Author.ID == botID {
-		return
+func isAllowed(channelID discord.ChannelID, author *discord.User) bool {
+	if author.ID == botID {
+		return false
 	}
 
 	config.ConfigMutex.Lock()
 	allowbots := config.Config.AllowBots
 	config.ConfigMutex.Unlock()
 
-	if c.Author.Bot && !allowbots {
-		return
+	if author.Bot && !allowbots {
+		return false
 	}
 
 	if len(config.Config.ChannelIds) > 0 &&
-		!utils.Contains(config.Config.ChannelIds, c.ChannelID.String()) {
-		return
+		!utils.Contains(config.Config.ChannelIds, channelID.String()) {
+		return false
 	}
 
-	if !canUse(c.Author.ID.String()) {
+	return canUse(author.ID.String())
+}
+
+func messageCreate(c *gateway.MessageCreateEvent) {
+	if !isAllowed(c.ChannelID, &c.Author) {
 		return
 	}
 
@@ -78,38 +81,13 @@ func messageCreate(c *gateway.MessageCreateEvent) {
 		args = "?"
 	}
 
-	settings, settingsInit := channels.Get(c.ChannelID.String())
-
-	if !settingsInit {
-		config.ConfigMutex.Lock()
-		settings = &command.ChannelSettings{
-			Prompt:         config.Config.DefaultPrompt,
-			NegativePrompt: config.Config.DefaultNegativePrompt,
-			Width:          config.Config.DefaultWidth,
-			Height:         config.Config.DefaultHeight,
-			PromptStrength: config.Config.DefaultPromptStrength,
-			InferenceSteps: config.Config.DefaultInferenceSteps,
-			GuidanceScale:  config.Config.DefaultGuidanceScale,
-			Sampler:        config.Config.DefaultSampler,
-			Upscaler:       config.Config.DefaultUpscaler,
-			UpscaleAmount:  config.Config.DefaultUpscaleAmount,
-			SessionID:      strconv.Itoa(rand.Int()),
-			InUse:          &atomic.Bool{},
-		}
-		config.ConfigMutex.Unlock()
-
-		appConfig, err := sdapi.GetAppConfig()
-		if err != nil {
-			_, _ = s.SendMessageReply(c.ChannelID, fmt.Sprintf("**Error:** %v", err), c.ID)
-			log.Println("Could not query app config:", err)
-			return
-		}
-
-		settings.Model = appConfig.Model.StableDiffusion
-		settings.VAE = appConfig.Model.VAE
-		settings.HyperNetwork = appConfig.Model.HyperNetwork
-
-		channels.Set(c.ChannelID.String(), settings)
+	unlock := lockChannelSettings(c.ChannelID)
+	settings, err := getChannelSettings(c.ChannelID)
+	if err != nil {
+		unlock()
+		_, _ = s.SendMessageReply(c.ChannelID, fmt.Sprintf("**Error:** %v", err), c.ID)
+		log.Println("Could not query app config:", err)
+		return
 	}
 
 	cmd := strings.ToLower(strings.Split(args, " ")[0])
@@ -123,7 +101,9 @@ func messageCreate(c *gateway.MessageCreateEvent) {
 		CalledWithAlias:  cmd,
 		Args:             args,
 		StopTyping:       stoptyping,
+		ReleaseSettings:  releaseChannelSettings(c.ChannelID, settings, unlock),
 	}
+	defer context.DoneWithSettings()
 
 	_ = s.Typing(c.ChannelID)
 	defer close(stoptyping)
@@ -165,8 +145,16 @@ func main() {
 	}
 
 	s = state.New("Bot " + config.Config.BotToken)
+	databasePath := config.Config.DatabasePath
 	config.ConfigMutex.Unlock()
+
+	if err := store.Open(databasePath); err != nil {
+		log.Fatalln("Could not open database:", err)
+	}
+	defer store.Close()
+
 	s.AddHandler(messageCreate)
+	s.AddHandler(interactionCreate)
 	s.AddIntents(gateway.IntentGuildMessages)
 	s.AddIntents(gateway.IntentDirectMessages)
 
@@ -176,25 +164,44 @@ func main() {
 	}
 
 	botID = self.ID
+	// Straight from the API, cached messages have the attachment links that expired
+	render.FetchMessage = s.Client.Message
 	executor = command.NewExecutor(s)
+	executor.RegisterCommand(commands.BatchCommand)
 	executor.RegisterCommand(commands.ClearCommand)
+	executor.RegisterCommand(commands.ControlNetCommand)
+	executor.RegisterCommand(commands.DynamicPromptsCommand)
+	executor.RegisterCommand(commands.FaceFixCommand)
+	executor.RegisterCommand(commands.FormatCommand)
 	executor.RegisterCommand(commands.GuidanceScaleCommand)
 	executor.RegisterCommand(commands.HelpCommand)
+	executor.RegisterCommand(commands.HistoryCommand)
 	executor.RegisterCommand(commands.HyperNetworkCommand)
+	executor.RegisterCommand(commands.ImportCommand)
 	executor.RegisterCommand(commands.InferenceStepsCommand)
 	executor.RegisterCommand(commands.ListModelsCommand)
+	executor.RegisterCommand(commands.LoraCommand)
 	executor.RegisterCommand(commands.ModelCommand)
 	executor.RegisterCommand(commands.NegativePromptCommand)
+	executor.RegisterCommand(commands.OutpaintCommand)
 	executor.RegisterCommand(commands.PromptCommand)
 	executor.RegisterCommand(commands.PromptStrengthCommand)
+	executor.RegisterCommand(commands.QualityCommand)
 	executor.RegisterCommand(commands.SamplerCommand)
 	executor.RegisterCommand(commands.RandomCommand)
 	executor.RegisterCommand(render.RenderCommand)
+	executor.RegisterCommand(commands.SeedCommand)
+	executor.RegisterCommand(commands.ReproduceCommand)
 	executor.RegisterCommand(commands.SizeCommand)
 	executor.RegisterCommand(commands.StopCommand)
+	executor.RegisterCommand(commands.TilingCommand)
+	executor.RegisterCommand(commands.UpscaleCommand)
 	executor.RegisterCommand(commands.UpscaleAmountCommand)
 	executor.RegisterCommand(commands.UpscalerCommand)
 	executor.RegisterCommand(commands.VaeCommand)
+	executor.RegisterCommand(commands.VariationCommand)
+	executor.RegisterCommand(commands.WildcardsCommand)
+	executor.RegisterCommand(commands.XYCommand)
 	executor.RegisterCommand(commands.ChatCommand)
 
 	if err := s.Open(context.Background()); err != nil {
@@ -204,10 +211,20 @@ func main() {
 
 	log.Println("Started as", self.Username)
 
+	if err := registerSlashCommands(); err != nil {
+		log.Println("Could not register slash commands:", err)
+	}
+
 	config.ConfigMutex.Lock()
 	frameUrl := config.Config.FrameUrl
+	galleryEnabled := config.Config.Gallery
+	frameHttpBind := config.Config.FrameHttpBind
 	config.ConfigMutex.Unlock()
 
+	if galleryEnabled {
+		gallery.Register(http.DefaultServeMux)
+	}
+
 	if frameUrl != "" {
 		http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 			split := strings.SplitN(r.RequestURI, "/", 3)
@@ -216,21 +233,25 @@ func main() {
 				return
 			}
 
-			channel, channelInit := channels.Get(split[1])
-			if !channelInit || channel.CurrentRenderInfo == nil || channel.CurrentRenderInfo.FrameData == nil {
+			messageID, err := discord.ParseSnowflake(split[1])
+			if err != nil {
+				w.WriteHeader(404)
+				return
+			}
+
+			job := render.GetJob(discord.MessageID(messageID))
+			if job == nil || job.FrameData() == nil {
 				w.WriteHeader(404)
 				return
 			}
 
 			w.Header().Add("Content-Type", "image/jpeg")
 			w.WriteHeader(200)
-			_, _ = w.Write(channel.CurrentRenderInfo.FrameData)
+			_, _ = w.Write(job.FrameData())
 		})
+	}
 
-		config.ConfigMutex.Lock()
-		frameHttpBind := config.Config.FrameHttpBind
-		config.ConfigMutex.Unlock()
-
+	if frameUrl != "" || galleryEnabled {
 		err = http.ListenAndServe(frameHttpBind, nil)
 
 		if err != nil {
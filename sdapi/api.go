@@ -1,19 +1,29 @@
 package sdapi
 
 import (
-	"bytes"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"net/http"
-	"strconv"
 	"time"
-
-	"github.com/ayunami2000/ayunsdcord/config"
 )
 
 var ErrResponseCode = errors.New("got unexpected response code")
+
+// Keeps the status code of a failed request, it still matches ErrResponseCode with errors.Is
+type ResponseError struct {
+	StatusCode int
+	Status     string
+}
+
+func (e *ResponseError) Error() string {
+	return fmt.Sprintf("%v: %s", ErrResponseCode, e.Status)
+}
+
+func (e *ResponseError) Unwrap() error {
+	return ErrResponseCode
+}
+
 var httpClient = http.Client{
 	Timeout:   10 * time.Minute, // Hopefully this doesn't break models that take too long to load
 	Transport: &httpTransport{},
@@ -21,121 +31,97 @@ var httpClient = http.Client{
 
 type httpTransport struct{}
 
-func getSDUrl() string {
-	config.ConfigMutex.Lock()
-	stableDiffusionURL := config.Config.StableDiffusionURL
-	config.ConfigMutex.Unlock()
-
-	return stableDiffusionURL
-}
-
 func (t *httpTransport) RoundTrip(req *http.Request) (*http.Response, error) {
-	config.ConfigMutex.Lock()
-	if config.Config.BasicAuth != "" {
-		req.Header.Set("Authorization", "Basic "+config.Config.BasicAuth)
-	}
-	config.ConfigMutex.Unlock()
-
 	res, err := http.DefaultTransport.RoundTrip(req)
 	if err != nil {
 		return nil, err
 	}
 
 	if res.StatusCode >= 400 && res.StatusCode != 425 {
-		return nil, fmt.Errorf("%w: %s", ErrResponseCode, res.Status)
+		res.Body.Close()
+		return nil, &ResponseError{StatusCode: res.StatusCode, Status: res.Status}
 	}
 
 	return res, nil
 }
 
-func GetModels() (*ModelsResponse, error) {
-	res, err := httpClient.Get(getSDUrl() + "/get/models")
-	if err != nil {
-		return nil, err
-	}
-
-	defer res.Body.Close()
-	var resParsed ModelsResponse
-	if err := json.NewDecoder(res.Body).Decode(&resParsed); err != nil {
-		return nil, err
-	}
-
-	return &resParsed, nil
+type endpoint struct {
+	URL       string
+	BasicAuth string
 }
 
-func GetAppConfig() (*AppConfigResponse, error) {
-	res, err := httpClient.Get(getSDUrl() + "/get/app_config")
+func (e *endpoint) do(method string, path string, contentType string, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequest(method, e.URL+path, body)
 	if err != nil {
 		return nil, err
 	}
 
-	defer res.Body.Close()
-	var resParsed AppConfigResponse
-
-	err = json.NewDecoder(res.Body).Decode(&resParsed)
-	return &resParsed, err
-}
-
-func Render(data *RenderData) (string, int64, error) {
-	var buf bytes.Buffer
-	if err := json.NewEncoder(&buf).Encode(data); err != nil {
-		return "", 0, err
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
 	}
 
-	res, err := httpClient.Post(getSDUrl()+"/render", "application/json", &buf)
-	if err != nil {
-		return "", 0, err
+	if e.BasicAuth != "" {
+		req.Header.Set("Authorization", "Basic "+e.BasicAuth)
 	}
 
-	defer res.Body.Close()
+	return httpClient.Do(req)
+}
 
-	var resParsed renderResponse
-	err = json.NewDecoder(res.Body).Decode(&resParsed)
-	return resParsed.Stream, resParsed.Task, err
+func (e *endpoint) get(path string) (*http.Response, error) {
+	return e.do(http.MethodGet, path, "", nil)
 }
 
-func StopRender(task int64) error {
-	res, err := httpClient.Get(getSDUrl() + "/image/stop?task=" + strconv.FormatInt(task, 10))
-	if err != nil {
-		return err
-	}
+func (e *endpoint) post(path string, contentType string, body io.Reader) (*http.Response, error) {
+	return e.do(http.MethodPost, path, contentType, body)
+}
 
-	res.Body.Close()
-	return nil
+func GetModels() (*ModelsResponse, error) {
+	var res *ModelsResponse
+	err := getPool().try(func(m *poolMember) (err error) {
+		res, err = m.backend.GetModels()
+		return
+	})
+
+	return res, err
 }
 
-func GetStream(streamURL string) ([]StreamResponse, error) {
-	res, err := httpClient.Get(getSDUrl() + streamURL)
-	if err != nil {
-		if res.StatusCode == 425 {
-			return []StreamResponse{}, nil
-		}
-		return nil, err
-	}
+func GetAppConfig() (*AppConfigResponse, error) {
+	var res *AppConfigResponse
+	err := getPool().try(func(m *poolMember) (err error) {
+		res, err = m.backend.GetAppConfig()
+		return
+	})
 
-	responses := []StreamResponse{}
-	var response StreamResponse
-	decoder := json.NewDecoder(res.Body)
-	defer res.Body.Close()
+	return res, err
+}
 
-	for {
-		if err := decoder.Decode(&response); errors.Is(err, io.EOF) {
-			break
-		} else if err != nil {
-			return responses, err
-		}
+// Renders can go to any backend, so only what all of them support is offered
+func GetSamplers() ([]string, error) {
+	return getPool().common(func(m *poolMember) ([]string, error) {
+		return m.backend.GetSamplers()
+	})
+}
 
-		responses = append(responses, response)
-	}
+func GetUpscalers() ([]string, error) {
+	return getPool().common(func(m *poolMember) ([]string, error) {
+		return m.backend.GetUpscalers()
+	})
+}
 
-	return responses, nil
+func GetFaceRestorers() ([]string, error) {
+	return getPool().common(func(m *poolMember) ([]string, error) {
+		return m.backend.GetFaceRestorers()
+	})
 }
 
-func GetImage(path string) (io.ReadCloser, error) {
-	res, err := httpClient.Get(getSDUrl() + path)
-	if err != nil {
-		return nil, err
-	}
+func GetTilingModes() ([]string, error) {
+	return getPool().common(func(m *poolMember) ([]string, error) {
+		return m.backend.TilingModes(), nil
+	})
+}
 
-	return res.Body, nil
+func GetOutputFormats() ([]string, error) {
+	return getPool().common(func(m *poolMember) ([]string, error) {
+		return m.backend.OutputFormats(), nil
+	})
 }
@@ -0,0 +1,411 @@
+package sdapi
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrNoImages = errors.New("no images in response")
+
+// Maps Easy Diffusion sampler names (used by the default config) to their AUTOMATIC1111 equivalents
+var automatic1111SamplerNames = map[string]string{
+	"plms":         "PLMS",
+	"ddim":         "DDIM",
+	"heun":         "Heun",
+	"euler":        "Euler",
+	"euler_a":      "Euler a",
+	"dpm2":         "DPM2",
+	"dpm2_a":       "DPM2 a",
+	"lms":          "LMS",
+	"dpmpp_2s_a":   "DPM++ 2S a",
+	"dpmpp_2m":     "DPM++ 2M",
+	"dpmpp_sde":    "DPM++ SDE",
+	"dpm_fast":     "DPM fast",
+	"dpm_adaptive": "DPM adaptive",
+	"unipc_snr":    "UniPC",
+	"unipc_tu":     "UniPC",
+	"unipc_snr_2":  "UniPC",
+	"unipc_tu_2":   "UniPC",
+	"unipc_tq":     "UniPC",
+}
+
+type automatic1111 struct {
+	endpoint
+	jobs *jobTracker
+	// Keeps the task IDs of this bot apart from those of earlier runs and other webui users
+	taskPrefix string
+}
+
+func newAutomatic1111(e endpoint) *automatic1111 {
+	return &automatic1111{endpoint: e, jobs: newJobTracker(), taskPrefix: strconv.FormatInt(time.Now().UnixNano(), 36)}
+}
+
+// The webui reports progress per task, given the ID the render was started with
+func (b *automatic1111) taskID(task int64) string {
+	return fmt.Sprintf("task(ayunsdcord-%s-%d)", b.taskPrefix, task)
+}
+
+func (b *automatic1111) getJSON(path string, v any) error {
+	res, err := b.get(path)
+	if err != nil {
+		return err
+	}
+
+	defer res.Body.Close()
+	return json.NewDecoder(res.Body).Decode(v)
+}
+
+func (b *automatic1111) postJSON(path string, data any, v any) error {
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(data); err != nil {
+		return err
+	}
+
+	res, err := b.post(path, "application/json", &buf)
+	if err != nil {
+		return err
+	}
+
+	defer res.Body.Close()
+	if v == nil {
+		return nil
+	}
+
+	return json.NewDecoder(res.Body).Decode(v)
+}
+
+func (b *automatic1111) GetModels() (*ModelsResponse, error) {
+	var models []a1111Model
+	if err := b.getJSON("/sdapi/v1/sd-models", &models); err != nil {
+		return nil, err
+	}
+
+	var vaes []a1111Named
+	if err := b.getJSON("/sdapi/v1/sd-vae", &vaes); err != nil {
+		return nil, err
+	}
+
+	var hypernetworks []a1111Named
+	if err := b.getJSON("/sdapi/v1/hypernetworks", &hypernetworks); err != nil {
+		return nil, err
+	}
+
+	var loras []a1111Named
+	if err := b.getJSON("/sdapi/v1/loras", &loras); err != nil {
+		return nil, err
+	}
+
+	// Without the ControlNet extension there are simply no ControlNet models
+	var controlNets a1111ControlNetModels
+	_ = b.getJSON("/controlnet/model_list", &controlNets)
+
+	var resParsed ModelsResponse
+	resParsed.Options.ControlNet = controlNets.ModelList
+	for _, m := range models {
+		resParsed.Options.StableDiffusion = append(resParsed.Options.StableDiffusion, m.Title)
+	}
+	for _, v := range vaes {
+		resParsed.Options.VAE = append(resParsed.Options.VAE, v.ModelName)
+	}
+	for _, h := range hypernetworks {
+		resParsed.Options.HyperNetwork = append(resParsed.Options.HyperNetwork, h.Name)
+	}
+	for _, l := range loras {
+		resParsed.Options.Lora = append(resParsed.Options.Lora, l.Name)
+	}
+
+	return &resParsed, nil
+}
+
+func (b *automatic1111) GetAppConfig() (*AppConfigResponse, error) {
+	var options a1111Options
+	if err := b.getJSON("/sdapi/v1/options", &options); err != nil {
+		return nil, err
+	}
+
+	var resParsed AppConfigResponse
+	resParsed.Model.StableDiffusion = options.SDModelCheckpoint
+	if options.SDVae != "Automatic" && options.SDVae != "None" {
+		resParsed.Model.VAE = options.SDVae
+	}
+
+	return &resParsed, nil
+}
+
+func (b *automatic1111) GetSamplers() ([]string, error) {
+	var samplers []a1111Named
+	if err := b.getJSON("/sdapi/v1/samplers", &samplers); err != nil {
+		return nil, err
+	}
+
+	names := []string{}
+	for _, s := range samplers {
+		names = append(names, s.Name)
+	}
+
+	return names, nil
+}
+
+func (b *automatic1111) GetUpscalers() ([]string, error) {
+	var upscalers []a1111Named
+	if err := b.getJSON("/sdapi/v1/upscalers", &upscalers); err != nil {
+		return nil, err
+	}
+
+	names := []string{}
+	for _, u := range upscalers {
+		if u.Name != "None" {
+			names = append(names, u.Name)
+		}
+	}
+
+	return names, nil
+}
+
+func (b *automatic1111) GetFaceRestorers() ([]string, error) {
+	var restorers []a1111Named
+	if err := b.getJSON("/sdapi/v1/face-restorers", &restorers); err != nil {
+		return nil, err
+	}
+
+	names := []string{}
+	for _, r := range restorers {
+		names = append(names, r.Name)
+	}
+
+	return names, nil
+}
+
+// The webui only tiles along both axes
+func (b *automatic1111) TilingModes() []string {
+	return []string{"xy"}
+}
+
+func (b *automatic1111) OutputFormats() []string {
+	return []string{"png", "jpeg"}
+}
+
+// The webui takes LoRAs as tags in the prompt
+func loraTags(data *RenderData) string {
+	tags := ""
+	for i, l := range data.UseLoraModel {
+		tags += fmt.Sprintf(" <lora:%s:%g>", l, data.LoraAlpha[i])
+	}
+
+	return tags
+}
+
+func (b *automatic1111) toRequest(data *RenderData) *a1111Request {
+	prompt := data.Prompt
+	if data.UseHypernetworkModel != "" {
+		prompt += " <hypernet:" + data.UseHypernetworkModel + ":1>"
+	}
+	prompt += loraTags(data)
+
+	sampler, exists := automatic1111SamplerNames[data.SamplerName]
+	if !exists {
+		sampler = data.SamplerName
+	}
+
+	req := &a1111Request{
+		Prompt:         prompt,
+		NegativePrompt: data.NegativePrompt,
+		Seed:           data.Seed,
+		Steps:          data.NumInferenceSteps,
+		CfgScale:       data.GuidanceScale,
+		Width:          data.Width,
+		Height:         data.Height,
+		SamplerName:    sampler,
+		BatchSize:      data.NumOutputs,
+		NIter:          1,
+		OverrideSettings: map[string]any{
+			"sd_model_checkpoint": data.UseStableDiffusionModel,
+		},
+		Subseed:         data.Subseed,
+		SubseedStrength: data.SubseedStrength,
+	}
+
+	if data.UseVaeModel != "" {
+		req.OverrideSettings["sd_vae"] = data.UseVaeModel
+	}
+
+	if data.UseFaceCorrection != "" {
+		req.RestoreFaces = true
+		req.OverrideSettings["face_restoration_model"] = data.UseFaceCorrection
+	}
+
+	req.Tiling = data.Tiling == "xy"
+
+	if data.InitImage != "" {
+		req.InitImages = []string{data.InitImage}
+		req.DenoisingStrength = data.PromptStrength
+
+		if data.Mask != "" {
+			req.Mask = data.Mask
+			// Start from the original image under the mask rather than noise
+			req.InpaintingFill = 1
+		}
+	}
+
+	if data.UseControlnetModel != "" && data.ControlImage != "" {
+		req.AlwaysOnScripts = map[string]any{
+			"controlnet": map[string]any{
+				"args": []a1111ControlNetUnit{{InputImage: data.ControlImage, Model: data.UseControlnetModel, Weight: data.ControlAlpha}},
+			},
+		}
+	}
+
+	return req
+}
+
+func (b *automatic1111) Render(data *RenderData) (string, int64, error) {
+	// Subseeds are supported, even if another backend emulated the variation before failing
+	data.VariationEmulated = false
+
+	// The render itself runs in the background, so check the webui is up while the pool can still move to another backend
+	res, err := b.get("/sdapi/v1/progress?skip_current_image=true")
+	if err != nil {
+		return "", 0, err
+	}
+	res.Body.Close()
+
+	stream, task := b.jobs.start(func(job *trackedJob) error {
+		req := b.toRequest(data)
+		req.ForceTaskID = b.taskID(job.id)
+		job.update(func(response *StreamResponse) {
+			response.TotalSteps = data.NumInferenceSteps
+		})
+
+		endpoint := "/sdapi/v1/txt2img"
+		if len(req.InitImages) > 0 {
+			endpoint = "/sdapi/v1/img2img"
+		}
+
+		var res a1111ImagesResponse
+		if err := b.postJSON(endpoint, req, &res); err != nil {
+			return err
+		}
+
+		if len(res.Images) < 1 {
+			return ErrNoImages
+		}
+
+		// The webui puts a grid of the whole batch in front of the images themselves
+		images := res.Images
+		if req.BatchSize > 0 && len(images) > int(req.BatchSize) {
+			images = images[len(images)-int(req.BatchSize):]
+		}
+
+		outputs := []StreamOutput{}
+		for _, image := range images {
+			if data.UseUpscale != "" && !job.isStopped() {
+				amount, _ := strconv.ParseFloat(data.UpscaleAmount, 64)
+				upscaled, err := b.upscale(image, data.UseUpscale, amount)
+				if err != nil {
+					return err
+				}
+
+				image = upscaled
+			}
+
+			outputs = append(outputs, StreamOutput{Data: "data:image/png;base64," + image})
+		}
+
+		job.update(func(response *StreamResponse) {
+			response.Step = data.NumInferenceSteps
+			response.TotalSteps = data.NumInferenceSteps
+			response.Output = outputs
+		})
+
+		return nil
+	})
+
+	return stream, task, nil
+}
+
+// Both take and return the image as plain base64
+func (b *automatic1111) upscale(image string, upscaler string, amount float64) (string, error) {
+	var upscaled a1111ExtraResponse
+	err := b.postJSON("/sdapi/v1/extra-single-image", &a1111ExtraRequest{
+		Image:           image,
+		UpscalingResize: amount,
+		Upscaler1:       upscaler,
+	}, &upscaled)
+
+	return upscaled.Image, err
+}
+
+func (b *automatic1111) Upscale(image []byte, upscaler string, amount uint) ([]byte, error) {
+	upscaled, err := b.upscale(base64.StdEncoding.EncodeToString(image), upscaler, float64(amount))
+	if err != nil {
+		return nil, err
+	}
+
+	return base64.StdEncoding.DecodeString(upscaled)
+}
+
+func (b *automatic1111) progress(task int64) (*a1111ProgressResponse, error) {
+	var progress a1111ProgressResponse
+	err := b.postJSON("/internal/progress", &a1111ProgressRequest{IDTask: b.taskID(task), IDLivePreview: -1, LivePreview: true}, &progress)
+	return &progress, err
+}
+
+func (b *automatic1111) StopRender(task int64) error {
+	if err := b.jobs.stop(task); err != nil {
+		return err
+	}
+
+	// Interrupting is not per task, so only do it while this render is the one running
+	if progress, err := b.progress(task); err != nil || !progress.Active {
+		return err
+	}
+
+	return b.postJSON("/sdapi/v1/interrupt", struct{}{}, nil)
+}
+
+func (b *automatic1111) GetStream(streamURL string) ([]StreamResponse, error) {
+	task, job, err := b.jobs.get(streamURL)
+	if err != nil {
+		return nil, err
+	}
+
+	if response, done := job.snapshot(); done {
+		b.jobs.remove(task)
+		return []StreamResponse{response}, nil
+	}
+
+	progress, err := b.progress(task)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot, _ := job.snapshot()
+	response := StreamResponse{TotalSteps: snapshot.TotalSteps}
+	if !progress.Active {
+		return []StreamResponse{response}, nil
+	}
+
+	response.Step = uint(math.Round(progress.Progress * float64(snapshot.TotalSteps)))
+	if strings.HasPrefix(progress.LivePreview, "data:") {
+		response.Output = []StreamOutput{{Data: progress.LivePreview}}
+	}
+
+	return []StreamResponse{response}, nil
+}
+
+func (b *automatic1111) GetImage(path string) (io.ReadCloser, error) {
+	res, err := b.get(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return res.Body, nil
+}
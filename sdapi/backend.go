@@ -0,0 +1,34 @@
+package sdapi
+
+import (
+	"io"
+	"strings"
+)
+
+type Backend interface {
+	GetModels() (*ModelsResponse, error)
+	GetAppConfig() (*AppConfigResponse, error)
+	GetSamplers() ([]string, error)
+	GetUpscalers() ([]string, error)
+	GetFaceRestorers() ([]string, error)
+	// Axes the backend can render seamless textures along, out of "x", "y" and "xy"
+	TilingModes() []string
+	// Image formats the backend renders in, out of "png", "jpeg" and "webp", the bot converts PNGs to JPEG itself
+	OutputFormats() []string
+	Render(data *RenderData) (string, int64, error)
+	StopRender(task int64) error
+	GetStream(streamURL string) ([]StreamResponse, error)
+	GetImage(path string) (io.ReadCloser, error)
+	// Runs only the upscaler on an existing image and returns the result as a PNG
+	Upscale(image []byte, upscaler string, amount uint) ([]byte, error)
+}
+
+func newBackend(kind string, e endpoint) Backend {
+	if strings.EqualFold(kind, "automatic1111") || strings.EqualFold(kind, "a1111") {
+		return newAutomatic1111(e)
+	} else if strings.EqualFold(kind, "comfyui") {
+		return newComfyUI(e)
+	}
+
+	return &easyDiffusion{endpoint: e}
+}
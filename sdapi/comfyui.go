@@ -0,0 +1,631 @@
+package sdapi
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"math/rand"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"text/template"
+	"time"
+
+	"github.com/ayunami2000/ayunsdcord/config"
+	"github.com/gorilla/websocket"
+	_ "golang.org/x/image/webp"
+)
+
+var ErrWorkflowFailed = errors.New("workflow execution failed")
+var ErrWorkflowInterrupted = errors.New("workflow execution interrupted")
+
+// Maps Easy Diffusion sampler names (used by the default config) to their ComfyUI equivalents
+var comfyUISamplerNames = map[string]string{
+	"euler_a":     "euler_ancestral",
+	"dpm2":        "dpm_2",
+	"dpm2_a":      "dpm_2_ancestral",
+	"dpmpp_2s_a":  "dpmpp_2s_ancestral",
+	"unipc_snr":   "uni_pc",
+	"unipc_tu":    "uni_pc",
+	"unipc_snr_2": "uni_pc_bh2",
+	"unipc_tu_2":  "uni_pc_bh2",
+	"unipc_tq":    "uni_pc",
+}
+
+// Values available to the workflow template, e.g. {{json .Prompt}} or {{.Seed}}
+type comfyUITemplateData struct {
+	*RenderData
+	Sampler string
+	// 1 for txt2img, the prompt strength for Img2Img
+	Denoise float64
+	// Names of the uploaded images, for LoadImage nodes
+	InitImageName    string
+	MaskName         string
+	ControlImageName string
+	// LoraLoader nodes chained after the checkpoint, and the node the model and CLIP come out of in the end
+	Loras     []comfyUILoraNode
+	ModelNode string
+}
+
+type comfyUILoraNode struct {
+	ID     string
+	Prev   string
+	Name   string
+	Weight float64
+}
+
+var ErrUnsupportedByWorkflow = errors.New("not supported by the ComfyUI workflow")
+
+// Features a render can ask for, which the workflow has to refer to so it cannot silently render without them
+var comfyUIFeatures = []struct {
+	name   string
+	fields []string
+	used   func(data *RenderData) bool
+}{
+	{"Img2Img", []string{".InitImage"}, func(data *RenderData) bool { return data.InitImage != "" }},
+	{"inpainting", []string{".Mask"}, func(data *RenderData) bool { return data.Mask != "" }},
+	{"ControlNet", []string{".ControlImage"}, func(data *RenderData) bool { return data.UseControlnetModel != "" && data.ControlImage != "" }},
+	{"LoRAs", []string{".Loras", ".UseLoraModel"}, func(data *RenderData) bool { return len(data.UseLoraModel) > 0 }},
+	{"tiling", []string{".Tiling"}, func(data *RenderData) bool { return data.Tiling != "" }},
+	{"face restoration", []string{".UseFaceCorrection"}, func(data *RenderData) bool { return data.UseFaceCorrection != "" }},
+}
+
+func readComfyWorkflow() (string, error) {
+	config.ConfigMutex.Lock()
+	workflowPath := config.Config.ComfyWorkflow
+	config.ConfigMutex.Unlock()
+
+	raw, err := os.ReadFile(workflowPath)
+	return string(raw), err
+}
+
+func workflowUses(raw string, fields ...string) bool {
+	for _, field := range fields {
+		if strings.Contains(raw, field) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func checkWorkflow(raw string, data *RenderData) error {
+	for _, feature := range comfyUIFeatures {
+		if feature.used(data) && !workflowUses(raw, feature.fields...) {
+			return fmt.Errorf("%s %w", feature.name, ErrUnsupportedByWorkflow)
+		}
+	}
+
+	return nil
+}
+
+// How long the websocket may stay quiet, renders queued behind others on the same ComfyUI hear nothing until they start
+const comfyUIIdleTimeout = 10 * time.Minute
+
+// A queued workflow, ComfyUI only says which one it is working on over the websocket
+type comfyUIPrompt struct {
+	id      string
+	conn    *websocket.Conn
+	running atomic.Bool
+}
+
+type comfyUI struct {
+	endpoint
+	jobs *jobTracker
+	// Prompts of the running tasks, by task number
+	prompts sync.Map
+}
+
+func newComfyUI(e endpoint) *comfyUI {
+	return &comfyUI{endpoint: e, jobs: newJobTracker()}
+}
+
+func (b *comfyUI) getOptions(node string, input string) ([]string, error) {
+	res, err := b.get("/object_info/" + node)
+	if err != nil {
+		return nil, err
+	}
+
+	defer res.Body.Close()
+	var resParsed map[string]comfyUIObjectInfo
+	if err := json.NewDecoder(res.Body).Decode(&resParsed); err != nil {
+		return nil, err
+	}
+
+	info, exists := resParsed[node]
+	if !exists {
+		return []string{}, nil
+	}
+
+	var options [][]string
+	if err := json.Unmarshal(info.Input.Required[input], &options); err != nil || len(options) < 1 {
+		return []string{}, nil
+	}
+
+	return options[0], nil
+}
+
+func (b *comfyUI) GetModels() (*ModelsResponse, error) {
+	var resParsed ModelsResponse
+	var err error
+
+	if resParsed.Options.StableDiffusion, err = b.getOptions("CheckpointLoaderSimple", "ckpt_name"); err != nil {
+		return nil, err
+	}
+
+	if resParsed.Options.VAE, err = b.getOptions("VAELoader", "vae_name"); err != nil {
+		return nil, err
+	}
+
+	if resParsed.Options.HyperNetwork, err = b.getOptions("HypernetworkLoader", "hypernetwork_name"); err != nil {
+		return nil, err
+	}
+
+	if resParsed.Options.Lora, err = b.getOptions("LoraLoader", "lora_name"); err != nil {
+		return nil, err
+	}
+
+	if resParsed.Options.ControlNet, err = b.getOptions("ControlNetLoader", "control_net_name"); err != nil {
+		return nil, err
+	}
+
+	return &resParsed, nil
+}
+
+// ComfyUI has no notion of a loaded model, so the first checkpoint is used as the default
+func (b *comfyUI) GetAppConfig() (*AppConfigResponse, error) {
+	models, err := b.getOptions("CheckpointLoaderSimple", "ckpt_name")
+	if err != nil {
+		return nil, err
+	}
+
+	var resParsed AppConfigResponse
+	if len(models) > 0 {
+		resParsed.Model.StableDiffusion = models[0]
+	}
+
+	return &resParsed, nil
+}
+
+func (b *comfyUI) GetSamplers() ([]string, error) {
+	return b.getOptions("KSampler", "sampler_name")
+}
+
+func (b *comfyUI) GetUpscalers() ([]string, error) {
+	return b.getOptions("UpscaleModelLoader", "model_name")
+}
+
+// Needs the facerestore_cf custom nodes and a workflow that uses .UseFaceCorrection
+func (b *comfyUI) GetFaceRestorers() ([]string, error) {
+	if raw, err := readComfyWorkflow(); err != nil || !workflowUses(raw, ".UseFaceCorrection") {
+		return []string{}, err
+	}
+
+	return b.getOptions("FaceRestoreModelLoader", "model_name")
+}
+
+// SaveImage only writes PNGs
+func (b *comfyUI) OutputFormats() []string {
+	return []string{"png", "jpeg"}
+}
+
+// ComfyUI has no tiling of its own, so it is only available if the workflow uses .Tiling
+func (b *comfyUI) TilingModes() []string {
+	if raw, err := readComfyWorkflow(); err != nil || !workflowUses(raw, ".Tiling") {
+		return nil
+	}
+
+	return []string{"x", "y", "xy"}
+}
+
+// Uploads an image given as a data URL, returning its name for LoadImage nodes
+func (b *comfyUI) uploadDataURL(dataURL string) (string, error) {
+	img, err := base64.StdEncoding.DecodeString(dataURL[strings.IndexByte(dataURL, ',')+1:])
+	if err != nil {
+		return "", err
+	}
+
+	return b.upload(img)
+}
+
+func (b *comfyUI) templateData(data *RenderData, sampler string) (*comfyUITemplateData, error) {
+	td := &comfyUITemplateData{RenderData: data, Sampler: sampler, Denoise: 1, ModelNode: "4"}
+
+	var err error
+	if data.InitImage != "" {
+		td.Denoise = data.PromptStrength
+		if td.InitImageName, err = b.uploadDataURL(data.InitImage); err != nil {
+			return nil, err
+		}
+
+		if data.Mask != "" {
+			if td.MaskName, err = b.uploadDataURL(data.Mask); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if data.UseControlnetModel != "" && data.ControlImage != "" {
+		if td.ControlImageName, err = b.uploadDataURL(data.ControlImage); err != nil {
+			return nil, err
+		}
+	}
+
+	for i, lora := range data.UseLoraModel {
+		node := comfyUILoraNode{ID: fmt.Sprintf("lora%d", i), Prev: td.ModelNode, Name: lora, Weight: data.LoraAlpha[i]}
+		td.Loras = append(td.Loras, node)
+		td.ModelNode = node.ID
+	}
+
+	return td, nil
+}
+
+func (b *comfyUI) buildWorkflow(data *RenderData) (map[string]any, error) {
+	raw, err := readComfyWorkflow()
+	if err != nil {
+		return nil, err
+	}
+
+	data = data.emulateVariation()
+	if err := checkWorkflow(raw, data); err != nil {
+		return nil, err
+	}
+
+	tmpl, err := template.New("workflow").Funcs(template.FuncMap{
+		"json": func(v any) (string, error) {
+			b, err := json.Marshal(v)
+			return string(b), err
+		},
+	}).Parse(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	sampler, exists := comfyUISamplerNames[data.SamplerName]
+	if !exists {
+		sampler = data.SamplerName
+	}
+
+	td, err := b.templateData(data, sampler)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, td); err != nil {
+		return nil, err
+	}
+
+	var workflow map[string]any
+	err = json.Unmarshal(buf.Bytes(), &workflow)
+	return workflow, err
+}
+
+func (b *comfyUI) dial(clientID string) (*websocket.Conn, error) {
+	wsURL := strings.Replace(b.URL, "http", "ws", 1) + "/ws?clientId=" + url.QueryEscape(clientID)
+
+	header := http.Header{}
+	if b.BasicAuth != "" {
+		header.Set("Authorization", "Basic "+b.BasicAuth)
+	}
+
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL, header)
+	return conn, err
+}
+
+// Queues the workflow, the returned connection receives its progress
+func (b *comfyUI) submit(workflow map[string]any) (*websocket.Conn, string, error) {
+	clientID := strconv.FormatInt(rand.Int63(), 16)
+	conn, err := b.dial(clientID)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(&comfyUIPromptRequest{Prompt: workflow, ClientID: clientID}); err != nil {
+		conn.Close()
+		return nil, "", err
+	}
+
+	res, err := b.post("/prompt", "application/json", &buf)
+	if err != nil {
+		conn.Close()
+		return nil, "", err
+	}
+
+	var prompt comfyUIPromptResponse
+	err = json.NewDecoder(res.Body).Decode(&prompt)
+	res.Body.Close()
+	if err != nil {
+		conn.Close()
+		return nil, "", err
+	}
+
+	return conn, prompt.PromptID, nil
+}
+
+func (b *comfyUI) Render(data *RenderData) (string, int64, error) {
+	workflow, err := b.buildWorkflow(data)
+	if err != nil {
+		return "", 0, err
+	}
+
+	conn, promptID, err := b.submit(workflow)
+	if err != nil {
+		return "", 0, err
+	}
+
+	prompt := &comfyUIPrompt{id: promptID, conn: conn}
+	stream, task := b.jobs.start(func(job *trackedJob) error {
+		defer conn.Close()
+		return b.follow(conn, prompt, job, time.Time{})
+	})
+	b.prompts.Store(task, prompt)
+
+	return stream, task, nil
+}
+
+// Stores the image in the input folder of ComfyUI, so a LoadImage node can use it
+func (b *comfyUI) upload(img []byte) (string, error) {
+	var buf bytes.Buffer
+	form := multipart.NewWriter(&buf)
+	part, err := form.CreateFormFile("image", fmt.Sprintf("ayunsdcord_%d.png", rand.Int63()))
+	if err != nil {
+		return "", err
+	}
+
+	if _, err := part.Write(img); err != nil {
+		return "", err
+	}
+
+	if err := form.Close(); err != nil {
+		return "", err
+	}
+
+	res, err := b.post("/upload/image", form.FormDataContentType(), &buf)
+	if err != nil {
+		return "", err
+	}
+
+	defer res.Body.Close()
+	var uploaded comfyUIUploadResponse
+	if err := json.NewDecoder(res.Body).Decode(&uploaded); err != nil {
+		return "", err
+	}
+
+	if uploaded.Subfolder != "" {
+		return uploaded.Subfolder + "/" + uploaded.Name, nil
+	}
+
+	return uploaded.Name, nil
+}
+
+// Upscale models have a fixed factor, so the result is scaled to the requested size afterwards
+func (b *comfyUI) Upscale(img []byte, upscaler string, amount uint) ([]byte, error) {
+	size, _, err := image.DecodeConfig(bytes.NewReader(img))
+	if err != nil {
+		return nil, err
+	}
+
+	name, err := b.upload(img)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, promptID, err := b.submit(map[string]any{
+		"1": map[string]any{"class_type": "LoadImage", "inputs": map[string]any{"image": name}},
+		"2": map[string]any{"class_type": "UpscaleModelLoader", "inputs": map[string]any{"model_name": upscaler}},
+		"3": map[string]any{"class_type": "ImageUpscaleWithModel", "inputs": map[string]any{"upscale_model": []any{"2", 0}, "image": []any{"1", 0}}},
+		"4": map[string]any{"class_type": "ImageScale", "inputs": map[string]any{
+			"upscale_method": "lanczos",
+			"width":          size.Width * int(amount),
+			"height":         size.Height * int(amount),
+			"crop":           "disabled",
+			"image":          []any{"3", 0},
+		}},
+		"5": map[string]any{"class_type": "SaveImage", "inputs": map[string]any{"filename_prefix": "ayunsdcord_upscale", "images": []any{"4", 0}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	defer conn.Close()
+	job := &trackedJob{}
+	if err := b.follow(conn, &comfyUIPrompt{id: promptID}, job, time.Now().Add(upscaleTimeout)); err != nil {
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			return nil, ErrUpscaleTimeout
+		}
+
+		return nil, err
+	}
+
+	response, _ := job.snapshot()
+	res, err := b.GetImage(response.Output[0].Path)
+	if err != nil {
+		return nil, err
+	}
+
+	defer res.Close()
+	return io.ReadAll(res)
+}
+
+// Reads the events of the prompt until it is done, giving up once the websocket stays quiet for too long or the deadline, if any, passes
+func (b *comfyUI) follow(conn *websocket.Conn, prompt *comfyUIPrompt, job *trackedJob, deadline time.Time) error {
+	for {
+		readDeadline := time.Now().Add(comfyUIIdleTimeout)
+		if !deadline.IsZero() && deadline.Before(readDeadline) {
+			readDeadline = deadline
+		}
+
+		if err := conn.SetReadDeadline(readDeadline); err != nil {
+			return err
+		}
+
+		messageType, msg, err := conn.ReadMessage()
+		if err != nil {
+			return err
+		}
+
+		// Binary messages are previews: a 4 byte event type, a 4 byte image format and the image itself
+		if messageType == websocket.BinaryMessage {
+			if len(msg) < 8 || binary.BigEndian.Uint32(msg[:4]) != 1 {
+				continue
+			}
+
+			mime := "image/jpeg"
+			if binary.BigEndian.Uint32(msg[4:8]) == 2 {
+				mime = "image/png"
+			}
+
+			job.update(func(response *StreamResponse) {
+				response.Output = []StreamOutput{{Data: "data:" + mime + ";base64," + base64.StdEncoding.EncodeToString(msg[8:])}}
+			})
+			continue
+		}
+
+		var event comfyUIEvent
+		if err := json.Unmarshal(msg, &event); err != nil {
+			continue
+		}
+
+		if event.Data.PromptID != "" && event.Data.PromptID != prompt.id {
+			continue
+		}
+
+		switch event.Type {
+		case "execution_start":
+			prompt.running.Store(true)
+		case "progress":
+			job.update(func(response *StreamResponse) {
+				response.Step = event.Data.Value
+				response.TotalSteps = event.Data.Max
+			})
+		case "execution_error":
+			return fmt.Errorf("%w: %s", ErrWorkflowFailed, event.Data.ExceptionMessage)
+		case "execution_interrupted":
+			return ErrWorkflowInterrupted
+		case "executing":
+			if event.Data.Node == nil {
+				return b.collect(prompt.id, job)
+			}
+
+			prompt.running.Store(true)
+		}
+	}
+}
+
+func (b *comfyUI) collect(promptID string, job *trackedJob) error {
+	res, err := b.get("/history/" + url.PathEscape(promptID))
+	if err != nil {
+		return err
+	}
+
+	defer res.Body.Close()
+	var history map[string]comfyUIHistory
+	if err := json.NewDecoder(res.Body).Decode(&history); err != nil {
+		return err
+	}
+
+	for _, output := range history[promptID].Outputs {
+		if len(output.Images) < 1 {
+			continue
+		}
+
+		outputs := []StreamOutput{}
+		for _, image := range output.Images {
+			query := url.Values{}
+			query.Set("filename", image.Filename)
+			query.Set("subfolder", image.Subfolder)
+			query.Set("type", image.Type)
+			outputs = append(outputs, StreamOutput{Path: "/view?" + query.Encode()})
+		}
+
+		job.update(func(response *StreamResponse) {
+			response.Step = response.TotalSteps
+			response.Output = outputs
+		})
+
+		return nil
+	}
+
+	return ErrNoImages
+}
+
+// Interrupting stops whatever ComfyUI is working on, so a prompt that did not start yet is taken out of the queue instead
+func (b *comfyUI) StopRender(task int64) error {
+	if err := b.jobs.stop(task); err != nil {
+		return err
+	}
+
+	value, exists := b.prompts.Load(task)
+	if !exists {
+		return nil
+	}
+
+	prompt := value.(*comfyUIPrompt)
+	if !prompt.running.Load() {
+		var buf bytes.Buffer
+		if err := json.NewEncoder(&buf).Encode(&comfyUIQueueRequest{Delete: []string{prompt.id}}); err != nil {
+			return err
+		}
+
+		res, err := b.post("/queue", "application/json", &buf)
+		if err != nil {
+			return err
+		}
+		res.Body.Close()
+
+		// It may have started in the meantime, then it has to be interrupted after all
+		if !prompt.running.Load() {
+			// No more events come for a deleted prompt, so stop waiting for them
+			prompt.conn.Close()
+			return nil
+		}
+	}
+
+	res, err := b.post("/interrupt", "application/json", nil)
+	if err != nil {
+		return err
+	}
+
+	res.Body.Close()
+	return nil
+}
+
+func (b *comfyUI) GetStream(streamURL string) ([]StreamResponse, error) {
+	task, job, err := b.jobs.get(streamURL)
+	if err != nil {
+		return nil, err
+	}
+
+	response, done := job.snapshot()
+	if done {
+		b.jobs.remove(task)
+		b.prompts.Delete(task)
+	}
+
+	return []StreamResponse{response}, nil
+}
+
+func (b *comfyUI) GetImage(path string) (io.ReadCloser, error) {
+	res, err := b.get(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return res.Body, nil
+}
@@ -0,0 +1,201 @@
+package sdapi
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrUpscaleFailed = errors.New("upscale failed")
+var ErrUpscaleTimeout = errors.New("upscale took too long")
+
+// Upscales are not streamed to anyone, so give up on them after this long
+const upscaleTimeout = 10 * time.Minute
+
+var easyDiffusionSamplers = []string{"plms", "ddim", "heun", "euler", "euler_a", "dpm2", "dpm2_a", "lms", "dpm_solver_stability", "dpmpp_2s_a", "dpmpp_2m", "dpmpp_sde", "dpm_fast", "dpm_adaptive", "unipc_snr", "unipc_tu", "unipc_snr_2", "unipc_tu_2", "unipc_tq"}
+var easyDiffusionUpscalers = []string{"RealESRGAN_x4plus", "RealESRGAN_x4plus_anime_6B"}
+var easyDiffusionFaceRestorers = []string{"GFPGANv1.4", "CodeFormer"}
+
+type easyDiffusion struct {
+	endpoint
+}
+
+func (b *easyDiffusion) GetModels() (*ModelsResponse, error) {
+	res, err := b.get("/get/models")
+	if err != nil {
+		return nil, err
+	}
+
+	defer res.Body.Close()
+	var resParsed ModelsResponse
+	if err := json.NewDecoder(res.Body).Decode(&resParsed); err != nil {
+		return nil, err
+	}
+
+	return &resParsed, nil
+}
+
+func (b *easyDiffusion) GetAppConfig() (*AppConfigResponse, error) {
+	res, err := b.get("/get/app_config")
+	if err != nil {
+		return nil, err
+	}
+
+	defer res.Body.Close()
+	var resParsed AppConfigResponse
+
+	err = json.NewDecoder(res.Body).Decode(&resParsed)
+	return &resParsed, err
+}
+
+func (b *easyDiffusion) GetSamplers() ([]string, error) {
+	return easyDiffusionSamplers, nil
+}
+
+func (b *easyDiffusion) GetUpscalers() ([]string, error) {
+	return easyDiffusionUpscalers, nil
+}
+
+func (b *easyDiffusion) GetFaceRestorers() ([]string, error) {
+	return easyDiffusionFaceRestorers, nil
+}
+
+func (b *easyDiffusion) TilingModes() []string {
+	return []string{"x", "y", "xy"}
+}
+
+func (b *easyDiffusion) OutputFormats() []string {
+	return []string{"png", "jpeg", "webp"}
+}
+
+func (b *easyDiffusion) Render(data *RenderData) (string, int64, error) {
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(data.emulateVariation()); err != nil {
+		return "", 0, err
+	}
+
+	res, err := b.post("/render", "application/json", &buf)
+	if err != nil {
+		return "", 0, err
+	}
+
+	defer res.Body.Close()
+
+	var resParsed renderResponse
+	err = json.NewDecoder(res.Body).Decode(&resParsed)
+	return resParsed.Stream, resParsed.Task, err
+}
+
+// Runs the upscaler as a filter task, which is streamed the same way as a render
+func (b *easyDiffusion) Upscale(image []byte, upscaler string, amount uint) ([]byte, error) {
+	var buf bytes.Buffer
+	err := json.NewEncoder(&buf).Encode(&easyDiffusionFilterRequest{
+		Image:        "data:" + http.DetectContentType(image) + ";base64," + base64.StdEncoding.EncodeToString(image),
+		Filter:       []string{"realesrgan"},
+		ModelPaths:   map[string]string{"realesrgan": upscaler},
+		FilterParams: map[string]any{"scale": amount},
+		OutputFormat: "png",
+		SessionId:    strconv.FormatInt(rand.Int63(), 10),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := b.post("/filter/", "application/json", &buf)
+	if err != nil {
+		return nil, err
+	}
+
+	var filter renderResponse
+	err = json.NewDecoder(res.Body).Decode(&filter)
+	res.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	for deadline := time.Now().Add(upscaleTimeout); time.Now().Before(deadline); {
+		responses, err := b.GetStream(filter.Stream)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, response := range responses {
+			if response.Status == "" || response.Status == "pending" {
+				continue
+			} else if response.Status != "succeeded" || len(response.Output) < 1 {
+				return nil, fmt.Errorf("%w: %s", ErrUpscaleFailed, response.Status)
+			}
+
+			output := response.Output[0]
+			if output.Data != "" {
+				return base64.StdEncoding.DecodeString(output.Data[strings.IndexByte(output.Data, ',')+1:])
+			}
+
+			image, err := b.GetImage(output.Path)
+			if err != nil {
+				return nil, err
+			}
+
+			defer image.Close()
+			return io.ReadAll(image)
+		}
+
+		time.Sleep(500 * time.Millisecond)
+	}
+
+	return nil, ErrUpscaleTimeout
+}
+
+func (b *easyDiffusion) StopRender(task int64) error {
+	res, err := b.get("/image/stop?task=" + strconv.FormatInt(task, 10))
+	if err != nil {
+		return err
+	}
+
+	res.Body.Close()
+	return nil
+}
+
+func (b *easyDiffusion) GetStream(streamURL string) ([]StreamResponse, error) {
+	res, err := b.get(streamURL)
+	if err != nil {
+		if res != nil && res.StatusCode == 425 {
+			return []StreamResponse{}, nil
+		}
+		return nil, err
+	}
+
+	responses := []StreamResponse{}
+	var response StreamResponse
+	decoder := json.NewDecoder(res.Body)
+	defer res.Body.Close()
+
+	for {
+		if err := decoder.Decode(&response); errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return responses, err
+		}
+
+		responses = append(responses, response)
+	}
+
+	return responses, nil
+}
+
+func (b *easyDiffusion) GetImage(path string) (io.ReadCloser, error) {
+	res, err := b.get(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return res.Body, nil
+}
@@ -0,0 +1,115 @@
+package sdapi
+
+import (
+	"errors"
+	"strconv"
+	"sync"
+)
+
+var ErrUnknownTask = errors.New("unknown task")
+
+// Backends without streaming endpoints run their requests in the background
+// and keep the latest state here so GetStream can be polled like Easy Diffusion.
+type trackedJob struct {
+	// The same as the task number, for requests that need to refer to the job before start returns
+	id       int64
+	mutex    sync.Mutex
+	response StreamResponse
+	done     bool
+	stopped  bool
+}
+
+func (j *trackedJob) update(f func(response *StreamResponse)) {
+	j.mutex.Lock()
+	f(&j.response)
+	j.mutex.Unlock()
+}
+
+func (j *trackedJob) snapshot() (StreamResponse, bool) {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	return j.response, j.done
+}
+
+func (j *trackedJob) isStopped() bool {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	return j.stopped
+}
+
+type jobTracker struct {
+	mutex sync.Mutex
+	next  int64
+	jobs  map[int64]*trackedJob
+}
+
+func newJobTracker() *jobTracker {
+	return &jobTracker{jobs: make(map[int64]*trackedJob)}
+}
+
+func (t *jobTracker) start(run func(job *trackedJob) error) (string, int64) {
+	t.mutex.Lock()
+	t.next++
+	task := t.next
+	job := &trackedJob{id: task}
+	t.jobs[task] = job
+	t.mutex.Unlock()
+
+	go func() {
+		err := run(job)
+		job.mutex.Lock()
+		if job.stopped {
+			job.response.Status = "stopped"
+			job.response.Output = nil
+		} else if err != nil {
+			job.response.Status = err.Error()
+			job.response.Output = nil
+		} else {
+			job.response.Status = "succeeded"
+		}
+		job.done = true
+		job.mutex.Unlock()
+	}()
+
+	return strconv.FormatInt(task, 10), task
+}
+
+func (t *jobTracker) get(streamURL string) (int64, *trackedJob, error) {
+	task, err := strconv.ParseInt(streamURL, 10, 64)
+	if err != nil {
+		return 0, nil, ErrUnknownTask
+	}
+
+	t.mutex.Lock()
+	job, exists := t.jobs[task]
+	t.mutex.Unlock()
+
+	if !exists {
+		return 0, nil, ErrUnknownTask
+	}
+
+	return task, job, nil
+}
+
+func (t *jobTracker) stop(task int64) error {
+	t.mutex.Lock()
+	job, exists := t.jobs[task]
+	t.mutex.Unlock()
+
+	if !exists {
+		return ErrUnknownTask
+	}
+
+	job.mutex.Lock()
+	job.stopped = true
+	job.mutex.Unlock()
+	return nil
+}
+
+func (t *jobTracker) remove(task int64) {
+	t.mutex.Lock()
+	delete(t.jobs, task)
+	t.mutex.Unlock()
+}
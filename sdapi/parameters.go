@@ -0,0 +1,235 @@
+package sdapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var ErrNoMetadata = errors.New("no metadata found")
+
+// Values with separators in them are quoted the same way the webui does it
+func quoteParameter(value string) string {
+	if !strings.ContainsAny(value, ",:\n\"") {
+		return value
+	}
+
+	quoted, _ := json.Marshal(value)
+	return string(quoted)
+}
+
+// Describes one image of a render in the "parameters" format of the AUTOMATIC1111 webui, which most tools can read back
+func FormatParameters(data *RenderData, index int) string {
+	seed, subseed := data.ImageSeeds(index)
+
+	var sb strings.Builder
+	sb.WriteString(data.Prompt + loraTags(data))
+	if data.NegativePrompt != "" {
+		sb.WriteString("\nNegative prompt: " + data.NegativePrompt)
+	}
+
+	sampler, exists := automatic1111SamplerNames[data.SamplerName]
+	if !exists {
+		sampler = data.SamplerName
+	}
+
+	fields := []string{
+		fmt.Sprintf("Steps: %d", data.NumInferenceSteps),
+		"Sampler: " + quoteParameter(sampler),
+		fmt.Sprintf("CFG scale: %g", data.GuidanceScale),
+		fmt.Sprintf("Seed: %d", seed),
+		fmt.Sprintf("Size: %dx%d", data.Width, data.Height),
+		"Model: " + quoteParameter(data.UseStableDiffusionModel),
+	}
+
+	if data.SubseedStrength > 0 && !data.VariationEmulated {
+		fields = append(fields, fmt.Sprintf("Variation seed: %d", subseed), fmt.Sprintf("Variation seed strength: %g", data.SubseedStrength))
+	}
+	if data.UseVaeModel != "" {
+		fields = append(fields, "VAE: "+quoteParameter(data.UseVaeModel))
+	}
+	if data.UseHypernetworkModel != "" {
+		fields = append(fields, "Hypernet: "+quoteParameter(data.UseHypernetworkModel))
+	}
+	if data.UseFaceCorrection != "" {
+		fields = append(fields, "Face restoration: "+quoteParameter(data.UseFaceCorrection))
+	}
+	if data.Tiling == "xy" {
+		fields = append(fields, "Tiling: True")
+	} else if data.Tiling != "" {
+		fields = append(fields, "Tiling: "+data.Tiling)
+	}
+	if data.VariationEmulated {
+		fields = append(fields, fmt.Sprintf("Denoising strength: %g", data.SubseedStrength))
+	} else if data.InitImage != "" {
+		fields = append(fields, fmt.Sprintf("Denoising strength: %g", data.PromptStrength))
+	}
+	if data.UseControlnetModel != "" {
+		fields = append(fields, "ControlNet 0: "+quoteParameter(fmt.Sprintf("Model: %s, Weight: %g", data.UseControlnetModel, data.ControlAlpha)))
+	}
+	if data.UseUpscale != "" {
+		fields = append(fields, "Postprocess upscaler: "+quoteParameter(data.UseUpscale), "Postprocess upscale by: "+data.UpscaleAmount)
+	}
+
+	sb.WriteString("\n" + strings.Join(fields, ", "))
+	return sb.String()
+}
+
+// Render parameters read back from an image of another tool
+type Parameters struct {
+	Prompt         string
+	NegativePrompt string
+	// Everything else by its name in the webui format, e.g. "Steps" or "CFG scale"
+	Fields map[string]string
+}
+
+var parameterFieldRegex = regexp.MustCompile(`\s*([\w][\w \-/]*):\s*("(?:\\.|[^\\"])*"|[^,]*)(?:,|$)`)
+
+// Parses the "parameters" text the AUTOMATIC1111 webui stores in its images
+func ParseParameters(text string) *Parameters {
+	params := &Parameters{Fields: map[string]string{}}
+	lines := strings.Split(strings.TrimSpace(text), "\n")
+
+	// The last line holds the settings, as long as it looks like it does
+	if last := lines[len(lines)-1]; len(parameterFieldRegex.FindAllString(last, -1)) >= 3 {
+		for _, match := range parameterFieldRegex.FindAllStringSubmatch(last, -1) {
+			value := strings.TrimSpace(match[2])
+			if strings.HasPrefix(value, "\"") {
+				_ = json.Unmarshal([]byte(value), &value)
+			}
+
+			params.Fields[strings.TrimSpace(match[1])] = value
+		}
+
+		lines = lines[:len(lines)-1]
+	}
+
+	prompt := []string{}
+	negativePrompt := []string{}
+	for _, line := range lines {
+		if strings.HasPrefix(line, "Negative prompt:") {
+			negativePrompt = append(negativePrompt, strings.TrimSpace(strings.TrimPrefix(line, "Negative prompt:")))
+		} else if len(negativePrompt) > 0 {
+			negativePrompt = append(negativePrompt, line)
+		} else {
+			prompt = append(prompt, line)
+		}
+	}
+
+	params.Prompt = strings.TrimSpace(strings.Join(prompt, "\n"))
+	params.NegativePrompt = strings.TrimSpace(strings.Join(negativePrompt, "\n"))
+	return params
+}
+
+// Easy Diffusion metadata keys, with the labels of its text format, and what the webui calls them
+var easyDiffusionMetadataFields = []struct{ key, label, field string }{
+	{"seed", "Seed", "Seed"},
+	{"use_stable_diffusion_model", "Stable Diffusion model", "Model"},
+	{"use_vae_model", "VAE model", "VAE"},
+	{"use_hypernetwork_model", "Hypernetwork model", "Hypernet"},
+	{"sampler_name", "Sampler", "Sampler"},
+	{"num_inference_steps", "Steps", "Steps"},
+	{"guidance_scale", "Guidance Scale", "CFG scale"},
+	{"prompt_strength", "Prompt Strength", "Denoising strength"},
+	{"clip_skip", "Clip Skip", "Clip skip"},
+	{"use_lora_model", "LoRA model", "LoRA"},
+	{"use_face_correction", "Use Face Correction", "Face restoration"},
+	{"use_upscale", "Use Upscaling", "Postprocess upscaler"},
+	{"upscale_amount", "Upscale By", "Postprocess upscale by"},
+}
+
+// Maps Easy Diffusion metadata, from a sidecar file or the text chunks of its PNGs, to the webui names
+func ParseEasyDiffusionMetadata(raw map[string]string) *Parameters {
+	params := &Parameters{Fields: map[string]string{}}
+	for key, value := range raw {
+		value = strings.TrimSpace(value)
+		switch strings.ToLower(key) {
+		case "prompt":
+			params.Prompt = value
+			continue
+		case "negative_prompt", "negative prompt":
+			params.NegativePrompt = value
+			continue
+		case "width", "height":
+			continue
+		}
+
+		field := key
+		for _, f := range easyDiffusionMetadataFields {
+			if strings.EqualFold(key, f.key) || strings.EqualFold(key, f.label) {
+				field = f.field
+				break
+			}
+		}
+
+		if value != "" && value != "None" && value != "null" {
+			params.Fields[field] = value
+		}
+	}
+
+	width, height := "", ""
+	for key, value := range raw {
+		if strings.EqualFold(key, "width") {
+			width = strings.TrimSpace(value)
+		} else if strings.EqualFold(key, "height") {
+			height = strings.TrimSpace(value)
+		}
+	}
+
+	if width != "" || height != "" {
+		params.Fields["Size"] = width + "x" + height
+	}
+
+	return params
+}
+
+// Splits an Easy Diffusion sidecar file, which is either JSON or "Label: value" lines, into keys and values
+func ReadEasyDiffusionSidecar(raw []byte) (map[string]string, error) {
+	values := map[string]string{}
+	if trimmed := bytes.TrimSpace(raw); bytes.HasPrefix(trimmed, []byte("{")) {
+		decoder := json.NewDecoder(bytes.NewReader(trimmed))
+		decoder.UseNumber()
+
+		var parsed map[string]any
+		if err := decoder.Decode(&parsed); err != nil {
+			return nil, err
+		}
+
+		for k, v := range parsed {
+			if v != nil {
+				values[k] = fmt.Sprint(v)
+			}
+		}
+
+		return values, nil
+	}
+
+	for _, line := range strings.Split(string(raw), "\n") {
+		if key, value, found := strings.Cut(line, ":"); found {
+			values[strings.TrimSpace(key)] = strings.TrimSpace(value)
+		}
+	}
+
+	if len(values) == 0 {
+		return nil, ErrNoMetadata
+	}
+
+	return values, nil
+}
+
+// Names the same sampler goes by in Easy Diffusion, the webui and ComfyUI
+func SamplerAliases(name string) []string {
+	aliases := []string{name}
+	for _, names := range []map[string]string{automatic1111SamplerNames, comfyUISamplerNames} {
+		for easyDiffusion, other := range names {
+			if strings.EqualFold(easyDiffusion, name) || strings.EqualFold(other, name) {
+				aliases = append(aliases, easyDiffusion, other)
+			}
+		}
+	}
+
+	return aliases
+}
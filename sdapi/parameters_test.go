@@ -0,0 +1,157 @@
+package sdapi
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseParameters(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want Parameters
+	}{
+		{
+			name: "webui",
+			text: "a cat, in a hat\nNegative prompt: blurry, lowres\nSteps: 20, Sampler: Euler a, CFG scale: 7, Seed: 1234, Size: 512x768, Model: sd15",
+			want: Parameters{
+				Prompt:         "a cat, in a hat",
+				NegativePrompt: "blurry, lowres",
+				Fields: map[string]string{
+					"Steps":     "20",
+					"Sampler":   "Euler a",
+					"CFG scale": "7",
+					"Seed":      "1234",
+					"Size":      "512x768",
+					"Model":     "sd15",
+				},
+			},
+		},
+		{
+			name: "quoted values",
+			text: "dog\nSteps: 30, Sampler: DDIM, Seed: 1, Model: \"a, b: c\", ControlNet 0: \"Model: canny, Weight: 1\"",
+			want: Parameters{
+				Prompt: "dog",
+				Fields: map[string]string{
+					"Steps":        "30",
+					"Sampler":      "DDIM",
+					"Seed":         "1",
+					"Model":        "a, b: c",
+					"ControlNet 0": "Model: canny, Weight: 1",
+				},
+			},
+		},
+		{
+			name: "multiline prompts",
+			text: "first line\nsecond line\nNegative prompt: bad\nworse\nSteps: 20, Seed: 5, Size: 512x512",
+			want: Parameters{
+				Prompt:         "first line\nsecond line",
+				NegativePrompt: "bad\nworse",
+				Fields:         map[string]string{"Steps": "20", "Seed": "5", "Size": "512x512"},
+			},
+		},
+		{
+			name: "prompt only",
+			text: "a cat: sitting, on a mat",
+			want: Parameters{Prompt: "a cat: sitting, on a mat", Fields: map[string]string{}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := ParseParameters(test.text); !reflect.DeepEqual(*got, test.want) {
+				t.Errorf("ParseParameters() = %#v, want %#v", *got, test.want)
+			}
+		})
+	}
+}
+
+func TestFormatParameters(t *testing.T) {
+	tests := []struct {
+		name  string
+		data  RenderData
+		index int
+		want  string
+	}{
+		{
+			name: "txt2img",
+			data: RenderData{Prompt: "cat", NegativePrompt: "dog", NumInferenceSteps: 20, SamplerName: "euler_a", GuidanceScale: 7.5, Seed: 42, Width: 512, Height: 512, UseStableDiffusionModel: "sd15"},
+			want: "cat\nNegative prompt: dog\nSteps: 20, Sampler: Euler a, CFG scale: 7.5, Seed: 42, Size: 512x512, Model: sd15",
+		},
+		{
+			name:  "batch image",
+			data:  RenderData{Prompt: "cat", NumInferenceSteps: 20, SamplerName: "DDIM", GuidanceScale: 7, Seed: 42, Width: 512, Height: 512, UseStableDiffusionModel: "sd15"},
+			index: 2,
+			want:  "cat\nSteps: 20, Sampler: DDIM, CFG scale: 7, Seed: 44, Size: 512x512, Model: sd15",
+		},
+		{
+			name:  "variation",
+			data:  RenderData{Prompt: "cat", NumInferenceSteps: 20, SamplerName: "DDIM", GuidanceScale: 7, Seed: 42, Width: 512, Height: 512, UseStableDiffusionModel: "sd15", Subseed: 100, SubseedStrength: 0.35},
+			index: 1,
+			want:  "cat\nSteps: 20, Sampler: DDIM, CFG scale: 7, Seed: 42, Size: 512x512, Model: sd15, Variation seed: 101, Variation seed strength: 0.35",
+		},
+		{
+			name:  "emulated variation",
+			data:  RenderData{Prompt: "cat", NumInferenceSteps: 20, SamplerName: "DDIM", GuidanceScale: 7, Seed: 42, Width: 512, Height: 512, UseStableDiffusionModel: "sd15", Subseed: 100, SubseedStrength: 0.35, VariationEmulated: true},
+			index: 1,
+			want:  "cat\nSteps: 20, Sampler: DDIM, CFG scale: 7, Seed: 43, Size: 512x512, Model: sd15, Denoising strength: 0.35",
+		},
+		{
+			name: "loras and quoting",
+			data: RenderData{Prompt: "cat", NumInferenceSteps: 20, SamplerName: "DDIM", GuidanceScale: 7, Seed: 1, Width: 512, Height: 512, UseStableDiffusionModel: "a, b", UseLoraModel: []string{"detail"}, LoraAlpha: []float64{0.5}, Tiling: "xy"},
+			want: "cat <lora:detail:0.5>\nSteps: 20, Sampler: DDIM, CFG scale: 7, Seed: 1, Size: 512x512, Model: \"a, b\", Tiling: True",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := FormatParameters(&test.data, test.index); got != test.want {
+				t.Errorf("FormatParameters() = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestParametersRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		data RenderData
+		want map[string]string
+	}{
+		{
+			name: "txt2img",
+			data: RenderData{Prompt: "a cat, in a hat", NegativePrompt: "blurry", NumInferenceSteps: 28, SamplerName: "dpmpp_2m", GuidanceScale: 12, Seed: 99, Width: 768, Height: 512, UseStableDiffusionModel: "model: v1, final"},
+			want: map[string]string{"Steps": "28", "Sampler": "DPM++ 2M", "CFG scale": "12", "Seed": "99", "Size": "768x512", "Model": "model: v1, final"},
+		},
+		{
+			name: "everything",
+			data: RenderData{
+				Prompt: "cat", NumInferenceSteps: 20, SamplerName: "euler", GuidanceScale: 7, Seed: 5, Width: 512, Height: 512, UseStableDiffusionModel: "sd15",
+				UseVaeModel: "vae", UseHypernetworkModel: "hyper", UseFaceCorrection: "CodeFormer", Tiling: "x",
+				InitImage: "data:image/png;base64,", PromptStrength: 0.6, UseControlnetModel: "canny", ControlAlpha: 0.8,
+				UseUpscale: "ESRGAN", UpscaleAmount: "4", Subseed: 7, SubseedStrength: 0.35,
+			},
+			want: map[string]string{
+				"Steps": "20", "Sampler": "Euler", "CFG scale": "7", "Seed": "5", "Size": "512x512", "Model": "sd15",
+				"Variation seed": "7", "Variation seed strength": "0.35", "VAE": "vae", "Hypernet": "hyper",
+				"Face restoration": "CodeFormer", "Tiling": "x", "Denoising strength": "0.6",
+				"ControlNet 0": "Model: canny, Weight: 0.8", "Postprocess upscaler": "ESRGAN", "Postprocess upscale by": "4",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := ParseParameters(FormatParameters(&test.data, 0))
+			if got.Prompt != test.data.Prompt {
+				t.Errorf("prompt = %q, want %q", got.Prompt, test.data.Prompt)
+			}
+			if got.NegativePrompt != test.data.NegativePrompt {
+				t.Errorf("negative prompt = %q, want %q", got.NegativePrompt, test.data.NegativePrompt)
+			}
+			if !reflect.DeepEqual(got.Fields, test.want) {
+				t.Errorf("fields = %v, want %v", got.Fields, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,380 @@
+package sdapi
+
+import (
+	"errors"
+	"io"
+	"log"
+	"net"
+	"reflect"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ayunami2000/ayunsdcord/config"
+)
+
+var ErrNoBackends = errors.New("no backends configured")
+
+type poolMember struct {
+	backend Backend
+	config  config.BackendConfig
+	active  atomic.Int32
+	healthy atomic.Bool
+}
+
+func (m *poolMember) concurrency() int32 {
+	if m.config.Concurrency != 0 {
+		return int32(m.config.Concurrency)
+	}
+
+	config.ConfigMutex.Lock()
+	concurrency := config.Config.RenderConcurrency
+	config.ConfigMutex.Unlock()
+
+	if concurrency == 0 {
+		return 1
+	}
+
+	return int32(concurrency)
+}
+
+func (m *poolMember) reserve() bool {
+	for {
+		active := m.active.Load()
+		if active >= m.concurrency() {
+			return false
+		}
+
+		if m.active.CompareAndSwap(active, active+1) {
+			return true
+		}
+	}
+}
+
+func (m *poolMember) release() {
+	m.active.Add(-1)
+
+	select {
+	case slotReleased <- struct{}{}:
+	default:
+	}
+}
+
+func (m *poolMember) load() float64 {
+	weight := m.config.Weight
+	if weight == 0 {
+		weight = 1
+	}
+
+	return float64(m.active.Load()) / float64(weight)
+}
+
+type pool struct {
+	configs []config.BackendConfig
+	members []*poolMember
+}
+
+var currentPool *pool
+var poolMutex = sync.Mutex{}
+var slotReleased = make(chan struct{}, 1)
+
+func init() {
+	go func() {
+		for {
+			config.ConfigMutex.Lock()
+			interval := config.Config.HealthCheckInterval
+			config.ConfigMutex.Unlock()
+
+			if interval == 0 {
+				time.Sleep(time.Minute)
+				continue
+			}
+
+			time.Sleep(time.Duration(interval) * time.Second)
+			getPool().checkHealth()
+		}
+	}()
+}
+
+func getBackendConfigs() []config.BackendConfig {
+	config.ConfigMutex.Lock()
+	defer config.ConfigMutex.Unlock()
+
+	if len(config.Config.Backends) > 0 {
+		return append([]config.BackendConfig{}, config.Config.Backends...)
+	}
+
+	return []config.BackendConfig{{
+		Backend:   config.Config.Backend,
+		URL:       config.Config.StableDiffusionURL,
+		BasicAuth: config.Config.BasicAuth,
+		Weight:    1,
+	}}
+}
+
+// Rebuilds the pool whenever the backend config changes, keeping members (and their running tasks) that still exist
+func getPool() *pool {
+	configs := getBackendConfigs()
+
+	poolMutex.Lock()
+	defer poolMutex.Unlock()
+
+	if currentPool != nil && reflect.DeepEqual(currentPool.configs, configs) {
+		return currentPool
+	}
+
+	existing := map[config.BackendConfig]*poolMember{}
+	if currentPool != nil {
+		for _, m := range currentPool.members {
+			existing[m.config] = m
+		}
+	}
+
+	p := &pool{configs: configs}
+	for _, c := range configs {
+		m, exists := existing[c]
+		if !exists {
+			m = &poolMember{
+				backend: newBackend(c.Backend, endpoint{URL: c.URL, BasicAuth: c.BasicAuth}),
+				config:  c,
+			}
+			m.healthy.Store(true)
+		}
+
+		p.members = append(p.members, m)
+	}
+
+	currentPool = p
+	return p
+}
+
+// Healthy members ordered by load, or every member if none are healthy
+func (p *pool) sorted() []*poolMember {
+	members := []*poolMember{}
+	for _, m := range p.members {
+		if m.healthy.Load() {
+			members = append(members, m)
+		}
+	}
+
+	if len(members) == 0 {
+		members = append(members, p.members...)
+	}
+
+	sort.SliceStable(members, func(i, j int) bool {
+		if members[i].load() != members[j].load() {
+			return members[i].load() < members[j].load()
+		}
+
+		return members[i].config.Weight > members[j].config.Weight
+	})
+
+	return members
+}
+
+// Connection problems and server errors, a 4xx is caused by the request and would fail on every backend
+func isBackendError(err error) bool {
+	var resErr *ResponseError
+	if errors.As(err, &resErr) {
+		return resErr.StatusCode >= 500
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+func (p *pool) markUnhealthy(m *poolMember, err error) {
+	if m.healthy.Swap(false) {
+		log.Printf("Backend %s failed, marking as unhealthy: %v\n", m.config.URL, err)
+	}
+}
+
+func (p *pool) try(f func(m *poolMember) error) error {
+	err := ErrNoBackends
+	for _, m := range p.sorted() {
+		if err = f(m); err == nil {
+			m.healthy.Store(true)
+			return nil
+		} else if !isBackendError(err) {
+			return err
+		}
+
+		p.markUnhealthy(m, err)
+	}
+
+	return err
+}
+
+// Options every reachable backend has, unreachable ones are marked unhealthy and left out
+func (p *pool) common(f func(m *poolMember) ([]string, error)) ([]string, error) {
+	var res []string
+	reached := false
+	err := ErrNoBackends
+	for _, m := range p.members {
+		var options []string
+		if options, err = f(m); err != nil {
+			if !isBackendError(err) {
+				return nil, err
+			}
+
+			p.markUnhealthy(m, err)
+			continue
+		}
+
+		m.healthy.Store(true)
+		if !reached {
+			res = options
+			reached = true
+			continue
+		}
+
+		shared := []string{}
+		for _, option := range res {
+			for _, o := range options {
+				if o == option {
+					shared = append(shared, option)
+					break
+				}
+			}
+		}
+
+		res = shared
+	}
+
+	if !reached {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// Reserves a render slot on the least busy backend that has one free
+func (p *pool) reserve(exclude map[*poolMember]bool) *poolMember {
+	for _, m := range p.sorted() {
+		if !exclude[m] && m.reserve() {
+			return m
+		}
+	}
+
+	return nil
+}
+
+func (p *pool) checkHealth() {
+	for _, m := range p.members {
+		if _, err := m.backend.GetAppConfig(); err != nil {
+			p.markUnhealthy(m, err)
+		} else if !m.healthy.Swap(true) {
+			log.Printf("Backend %s is healthy again\n", m.config.URL)
+		}
+	}
+}
+
+// Whether a backend has a render slot free at the moment, without reserving it
+func HasFreeSlot() bool {
+	for _, m := range getPool().sorted() {
+		if m.active.Load() < m.concurrency() {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Blocks until a backend has a free render slot and reserves it for the caller
+func Acquire() *Slot {
+	for {
+		if m := getPool().reserve(nil); m != nil {
+			return &Slot{member: m}
+		}
+
+		// Health checks and config changes can also free up slots, so don't rely on releases alone
+		select {
+		case <-slotReleased:
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+type Slot struct {
+	member   *poolMember
+	released atomic.Bool
+}
+
+// Starts a render on the reserved backend, moving to another backend with a free slot if it fails
+func (s *Slot) Render(data *RenderData) (*Task, error) {
+	tried := map[*poolMember]bool{}
+	for {
+		stream, id, err := s.member.backend.Render(data)
+		if err == nil {
+			s.member.healthy.Store(true)
+			return &Task{ID: id, Stream: stream, slot: s}, nil
+		}
+
+		if !isBackendError(err) {
+			s.Release()
+			return nil, err
+		}
+
+		getPool().markUnhealthy(s.member, err)
+		tried[s.member] = true
+
+		next := getPool().reserve(tried)
+		if next == nil {
+			s.Release()
+			return nil, err
+		}
+
+		s.member.release()
+		s.member = next
+	}
+}
+
+// Upscales an existing image on the reserved backend, the slot stays reserved until it is released
+func (s *Slot) Upscale(image []byte, upscaler string, amount uint) ([]byte, error) {
+	upscaled, err := s.member.backend.Upscale(image, upscaler, amount)
+	if err != nil && isBackendError(err) {
+		getPool().markUnhealthy(s.member, err)
+	}
+
+	return upscaled, err
+}
+
+// URL of the backend the slot is on, which can change when a render moves to another backend
+func (s *Slot) Backend() string {
+	return s.member.config.URL
+}
+
+func (s *Slot) Release() {
+	if s.released.CompareAndSwap(false, true) {
+		s.member.release()
+	}
+}
+
+// A render running on a specific backend
+type Task struct {
+	ID     int64
+	Stream string
+	slot   *Slot
+}
+
+func (t *Task) Backend() string {
+	return t.slot.Backend()
+}
+
+func (t *Task) Stop() error {
+	return t.slot.member.backend.StopRender(t.ID)
+}
+
+func (t *Task) GetStream() ([]StreamResponse, error) {
+	return t.slot.member.backend.GetStream(t.Stream)
+}
+
+func (t *Task) GetImage(path string) (io.ReadCloser, error) {
+	return t.slot.member.backend.GetImage(path)
+}
+
+// Frees up the backend for other renders
+func (t *Task) Close() {
+	t.slot.Release()
+}
@@ -1,10 +1,14 @@
 package sdapi
 
+import "encoding/json"
+
 type ModelsResponse struct {
 	Options struct {
 		StableDiffusion []string `json:"stable-diffusion"`
 		VAE             []string `json:"vae"`
 		HyperNetwork    []string `json:"hypernetwork"`
+		ControlNet      []string `json:"controlnet"`
+		Lora            []string `json:"lora"`
 	} `json:"options"`
 }
 
@@ -17,34 +21,96 @@ type AppConfigResponse struct {
 }
 
 type RenderData struct {
-	Prompt                      string   `json:"prompt"`
-	Seed                        int      `json:"seed"`
-	NegativePrompt              string   `json:"negative_prompt"`
-	NumOutputs                  uint     `json:"num_outputs"`
-	NumInferenceSteps           uint     `json:"num_inference_steps"`
-	GuidanceScale               float64  `json:"guidance_scale"`
-	Width                       uint     `json:"width"`
-	Height                      uint     `json:"height"`
-	VramUsageLevel              string   `json:"vram_usage_level"`
-	UseStableDiffusionModel     string   `json:"use_stable_diffusion_model"`
-	UseVaeModel                 string   `json:"use_vae_model,omitempty"`
-	UseHypernetworkModel        string   `json:"use_hypernetwork_model,omitempty"`
-	StreamProgressUpdates       bool     `json:"stream_progress_updates"`
-	StreamImageProgress         bool     `json:"stream_image_progress"`
-	StreamImageProgressInterval uint     `json:"stream_image_progress_interval"`
-	ShowOnlyFilteredImage       bool     `json:"show_only_filtered_image"`
-	OutputFormat                string   `json:"output_format"`
-	OutputQuality               uint     `json:"output_quality"`
-	MetadataOutputFormat        string   `json:"metadata_output_format"`
-	OriginalPrompt              string   `json:"original_prompt"`
-	ActiveTags                  []string `json:"active_tags"`
-	InactiveTags                []string `json:"inactive_tags"`
-	SamplerName                 string   `json:"sampler_name"`
-	SessionId                   string   `json:"session_id"`
-	InitImage                   string   `json:"init_image,omitempty"`
-	PromptStrength              float64  `json:"prompt_strength,omitempty"`
-	UseUpscale                  string   `json:"use_upscale,omitempty"`
-	UpscaleAmount               string   `json:"upscale_amount,omitempty"`
+	Prompt                      string    `json:"prompt"`
+	Seed                        int       `json:"seed"`
+	NegativePrompt              string    `json:"negative_prompt"`
+	NumOutputs                  uint      `json:"num_outputs"`
+	NumInferenceSteps           uint      `json:"num_inference_steps"`
+	GuidanceScale               float64   `json:"guidance_scale"`
+	Width                       uint      `json:"width"`
+	Height                      uint      `json:"height"`
+	VramUsageLevel              string    `json:"vram_usage_level"`
+	UseStableDiffusionModel     string    `json:"use_stable_diffusion_model"`
+	UseVaeModel                 string    `json:"use_vae_model,omitempty"`
+	UseHypernetworkModel        string    `json:"use_hypernetwork_model,omitempty"`
+	UseLoraModel                []string  `json:"use_lora_model,omitempty"`
+	LoraAlpha                   []float64 `json:"lora_alpha,omitempty"`
+	StreamProgressUpdates       bool      `json:"stream_progress_updates"`
+	StreamImageProgress         bool      `json:"stream_image_progress"`
+	StreamImageProgressInterval uint      `json:"stream_image_progress_interval"`
+	ShowOnlyFilteredImage       bool      `json:"show_only_filtered_image"`
+	OutputFormat                string    `json:"output_format"`
+	OutputQuality               uint      `json:"output_quality"`
+	MetadataOutputFormat        string    `json:"metadata_output_format"`
+	OriginalPrompt              string    `json:"original_prompt"`
+	ActiveTags                  []string  `json:"active_tags"`
+	InactiveTags                []string  `json:"inactive_tags"`
+	SamplerName                 string    `json:"sampler_name"`
+	SessionId                   string    `json:"session_id"`
+	InitImage                   string    `json:"init_image,omitempty"`
+	Mask                        string    `json:"mask,omitempty"`
+	PromptStrength              float64   `json:"prompt_strength,omitempty"`
+	UseUpscale                  string    `json:"use_upscale,omitempty"`
+	UpscaleAmount               string    `json:"upscale_amount,omitempty"`
+	UseControlnetModel          string    `json:"use_controlnet_model,omitempty"`
+	ControlImage                string    `json:"control_image,omitempty"`
+	ControlAlpha                float64   `json:"control_alpha,omitempty"`
+	Subseed                     int       `json:"subseed,omitempty"`
+	SubseedStrength             float64   `json:"subseed_strength,omitempty"`
+	UseFaceCorrection           string    `json:"use_face_correction,omitempty"`
+	// Empty, "x", "y" or "xy"
+	Tiling string `json:"tiling,omitempty"`
+	// Data URL of the image a variation is made from, for backends without subseeds
+	VariationImage string `json:"variation_image,omitempty"`
+	// Set by backends that made the variation with Img2Img, the images then differ in their seed like any other batch
+	VariationEmulated bool `json:"variation_emulated,omitempty"`
+}
+
+// Seed and subseed of one image of a batch, the images of a variation only differ in their subseed unless it was emulated
+func (d *RenderData) ImageSeeds(index int) (int, int) {
+	if d.SubseedStrength > 0 && !d.VariationEmulated {
+		return d.Seed, d.Subseed + index
+	}
+
+	return d.Seed + index, d.Subseed
+}
+
+// A copy that shares no slices with the original, so either can be changed
+func (d *RenderData) Copy() *RenderData {
+	data := *d
+	data.UseLoraModel = append([]string(nil), d.UseLoraModel...)
+	data.LoraAlpha = append([]float64(nil), d.LoraAlpha...)
+	data.ActiveTags = append([]string{}, d.ActiveTags...)
+	data.InactiveTags = append([]string{}, d.InactiveTags...)
+	return &data
+}
+
+// Backends without subseeds make variations with a low strength Img2Img of the original image instead, this marks the render as emulated
+func (d *RenderData) emulateVariation() *RenderData {
+	d.VariationEmulated = d.VariationImage != ""
+	if !d.VariationEmulated {
+		return d
+	}
+
+	// The variation image already has any Img2Img or inpainting of the original render in it
+	data := *d
+	data.InitImage = data.VariationImage
+	data.Mask = ""
+	data.PromptStrength = data.SubseedStrength
+	data.VariationImage = ""
+	data.VariationEmulated = false
+	data.Subseed = 0
+	data.SubseedStrength = 0
+	return &data
+}
+
+type easyDiffusionFilterRequest struct {
+	Image        string            `json:"image"`
+	Filter       []string          `json:"filter"`
+	ModelPaths   map[string]string `json:"model_paths"`
+	FilterParams map[string]any    `json:"filter_params"`
+	OutputFormat string            `json:"output_format"`
+	SessionId    string            `json:"session_id"`
 }
 
 type renderResponse struct {
@@ -52,12 +118,139 @@ type renderResponse struct {
 	Task   int64  `json:"task"`
 }
 
+type StreamOutput struct {
+	Path string `json:"path,omitempty"`
+	Data string `json:"data,omitempty"`
+}
+
 type StreamResponse struct {
-	Output []struct {
-		Path string `json:"path,omitempty"`
-		Data string `json:"data,omitempty"`
-	} `json:"output"`
-	Step       uint   `json:"step,omitempty"`
-	TotalSteps uint   `json:"total_steps,omitempty"`
-	Status     string `json:"status,omitempty"`
+	Output     []StreamOutput `json:"output"`
+	Step       uint           `json:"step,omitempty"`
+	TotalSteps uint           `json:"total_steps,omitempty"`
+	Status     string         `json:"status,omitempty"`
+}
+
+type a1111Named struct {
+	Name      string `json:"name"`
+	ModelName string `json:"model_name"`
+}
+
+type a1111Model struct {
+	Title     string `json:"title"`
+	ModelName string `json:"model_name"`
+}
+
+type a1111Options struct {
+	SDModelCheckpoint string `json:"sd_model_checkpoint"`
+	SDVae             string `json:"sd_vae"`
+}
+
+type a1111Request struct {
+	Prompt            string         `json:"prompt"`
+	NegativePrompt    string         `json:"negative_prompt"`
+	Seed              int            `json:"seed"`
+	Steps             uint           `json:"steps"`
+	CfgScale          float64        `json:"cfg_scale"`
+	Width             uint           `json:"width"`
+	Height            uint           `json:"height"`
+	SamplerName       string         `json:"sampler_name"`
+	BatchSize         uint           `json:"batch_size"`
+	NIter             uint           `json:"n_iter"`
+	OverrideSettings  map[string]any `json:"override_settings"`
+	InitImages        []string       `json:"init_images,omitempty"`
+	DenoisingStrength float64        `json:"denoising_strength,omitempty"`
+	Mask              string         `json:"mask,omitempty"`
+	InpaintingFill    int            `json:"inpainting_fill,omitempty"`
+	AlwaysOnScripts   map[string]any `json:"alwayson_scripts,omitempty"`
+	Subseed           int            `json:"subseed,omitempty"`
+	SubseedStrength   float64        `json:"subseed_strength,omitempty"`
+	RestoreFaces      bool           `json:"restore_faces,omitempty"`
+	Tiling            bool           `json:"tiling,omitempty"`
+	ForceTaskID       string         `json:"force_task_id,omitempty"`
+}
+
+// Provided by the sd-webui-controlnet extension
+type a1111ControlNetModels struct {
+	ModelList []string `json:"model_list"`
+}
+
+type a1111ControlNetUnit struct {
+	InputImage string  `json:"input_image"`
+	Model      string  `json:"model"`
+	Weight     float64 `json:"weight"`
+}
+
+type a1111ImagesResponse struct {
+	Images []string `json:"images"`
+	Info   string   `json:"info"`
+}
+
+type a1111ExtraRequest struct {
+	Image           string  `json:"image"`
+	UpscalingResize float64 `json:"upscaling_resize"`
+	Upscaler1       string  `json:"upscaler_1"`
+}
+
+type a1111ExtraResponse struct {
+	Image string `json:"image"`
+}
+
+// The progress of one task, as the webui's own frontend polls it
+type a1111ProgressRequest struct {
+	IDTask        string `json:"id_task"`
+	IDLivePreview int    `json:"id_live_preview"`
+	LivePreview   bool   `json:"live_preview"`
+}
+
+type a1111ProgressResponse struct {
+	Active      bool    `json:"active"`
+	Queued      bool    `json:"queued"`
+	Completed   bool    `json:"completed"`
+	Progress    float64 `json:"progress"`
+	LivePreview string  `json:"live_preview"`
+}
+
+type comfyUIObjectInfo struct {
+	Input struct {
+		Required map[string]json.RawMessage `json:"required"`
+	} `json:"input"`
+}
+
+type comfyUIPromptRequest struct {
+	Prompt   map[string]any `json:"prompt"`
+	ClientID string         `json:"client_id"`
+}
+
+type comfyUIQueueRequest struct {
+	Delete []string `json:"delete"`
+}
+
+type comfyUIUploadResponse struct {
+	Name      string `json:"name"`
+	Subfolder string `json:"subfolder"`
+}
+
+type comfyUIPromptResponse struct {
+	PromptID string `json:"prompt_id"`
+}
+
+type comfyUIEvent struct {
+	Type string `json:"type"`
+	Data struct {
+		PromptID         string  `json:"prompt_id"`
+		Node             *string `json:"node"`
+		Value            uint    `json:"value"`
+		Max              uint    `json:"max"`
+		ExceptionMessage string  `json:"exception_message"`
+	} `json:"data"`
+}
+
+type comfyUIHistory struct {
+	Outputs map[string]struct {
+		Images []struct {
+			Filename  string `json:"filename"`
+			Subfolder string `json:"subfolder"`
+			Type      string `json:"type"`
+		} `json:"images"`
+	} `json:"outputs"`
 }
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"math/rand"
+	"strconv"
+	"sync"
+
+	"github.com/ayunami2000/ayunsdcord/commands/command"
+	"github.com/ayunami2000/ayunsdcord/config"
+	"github.com/ayunami2000/ayunsdcord/sdapi"
+	"github.com/ayunami2000/ayunsdcord/store"
+	"github.com/diamondburned/arikawa/v3/discord"
+)
+
+const channelsBucket = "channels"
+
+// One mutex per channel, so commands of a channel take turns changing its settings
+var channelSettingsMutexes sync.Map
+
+// Locks the settings of the channel until the returned function is called
+func lockChannelSettings(channelID discord.ChannelID) func() {
+	mutex, _ := channelSettingsMutexes.LoadOrStore(channelID, &sync.Mutex{})
+	mutex.(*sync.Mutex).Lock()
+	return mutex.(*sync.Mutex).Unlock
+}
+
+// Saves the settings if the command changed them and unlocks them again
+func releaseChannelSettings(channelID discord.ChannelID, settings *command.ChannelSettings, unlock func()) func() {
+	previous := encodeChannelSettings(settings)
+	return func() {
+		saveChannelSettings(channelID, settings, previous)
+		unlock()
+	}
+}
+
+// Loads the settings of a channel from the cache, then the database, and otherwise creates them from the defaults
+func getChannelSettings(channelID discord.ChannelID) (*command.ChannelSettings, error) {
+	settings, settingsInit := channels.Get(channelID.String())
+	if settingsInit {
+		return settings, nil
+	}
+
+	config.ConfigMutex.Lock()
+	settings = &command.ChannelSettings{
+		Prompt:         config.Config.DefaultPrompt,
+		NegativePrompt: config.Config.DefaultNegativePrompt,
+		Width:          config.Config.DefaultWidth,
+		Height:         config.Config.DefaultHeight,
+		PromptStrength: config.Config.DefaultPromptStrength,
+		InferenceSteps: config.Config.DefaultInferenceSteps,
+		GuidanceScale:  config.Config.DefaultGuidanceScale,
+		Sampler:        config.Config.DefaultSampler,
+		Upscaler:       config.Config.DefaultUpscaler,
+		UpscaleAmount:  config.Config.DefaultUpscaleAmount,
+		BatchSize:      1,
+		OutputFormat:   "png",
+		OutputQuality:  75,
+		Seed:           -1,
+		SessionID:      strconv.Itoa(rand.Int()),
+	}
+	config.ConfigMutex.Unlock()
+
+	stored, err := store.Get(channelsBucket, channelID.String(), settings)
+	if err != nil {
+		log.Println("Could not load channel settings:", err)
+	}
+
+	if !stored {
+		appConfig, err := sdapi.GetAppConfig()
+		if err != nil {
+			return nil, err
+		}
+
+		settings.Model = appConfig.Model.StableDiffusion
+		settings.VAE = appConfig.Model.VAE
+		settings.HyperNetwork = appConfig.Model.HyperNetwork
+
+		saveChannelSettings(channelID, settings, nil)
+	}
+
+	channels.Set(channelID.String(), settings)
+	return settings, nil
+}
+
+func encodeChannelSettings(settings *command.ChannelSettings) []byte {
+	encoded, _ := json.Marshal(settings)
+	return encoded
+}
+
+// Writes the settings to the database if they differ from the previously encoded ones
+func saveChannelSettings(channelID discord.ChannelID, settings *command.ChannelSettings, previous []byte) {
+	if previous != nil && bytes.Equal(previous, encodeChannelSettings(settings)) {
+		return
+	}
+
+	if err := store.Put(channelsBucket, channelID.String(), settings); err != nil {
+		log.Println("Could not save channel settings:", err)
+	}
+}
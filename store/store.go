@@ -0,0 +1,115 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var ErrNotOpen = errors.New("database is not open")
+
+var db *bolt.DB
+
+func Open(path string) (err error) {
+	db, err = bolt.Open(path, 0600, nil)
+	return err
+}
+
+func Close() error {
+	if db == nil {
+		return ErrNotOpen
+	}
+
+	return db.Close()
+}
+
+// Decodes the value stored under key into v, returns false if there is none
+func Get(bucket string, key string, v any) (bool, error) {
+	if db == nil {
+		return false, ErrNotOpen
+	}
+
+	var raw []byte
+	err := db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(bucket))
+		if b == nil {
+			return nil
+		}
+
+		if value := b.Get([]byte(key)); value != nil {
+			raw = append([]byte{}, value...)
+		}
+
+		return nil
+	})
+
+	if err != nil || raw == nil {
+		return false, err
+	}
+
+	return true, json.Unmarshal(raw, v)
+}
+
+func Put(bucket string, key string, v any) error {
+	if db == nil {
+		return ErrNotOpen
+	}
+
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	return db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists([]byte(bucket))
+		if err != nil {
+			return err
+		}
+
+		return b.Put([]byte(key), raw)
+	})
+}
+
+func Delete(bucket string, key string) error {
+	if db == nil {
+		return ErrNotOpen
+	}
+
+	return db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(bucket))
+		if b == nil {
+			return nil
+		}
+
+		return b.Delete([]byte(key))
+	})
+}
+
+// Decodes the values of the bucket from the last key to the first and calls fn with them, until it returns false
+func Reverse[V any](bucket string, fn func(key string, v *V) bool) error {
+	if db == nil {
+		return ErrNotOpen
+	}
+
+	return db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(bucket))
+		if b == nil {
+			return nil
+		}
+
+		c := b.Cursor()
+		for k, raw := c.Last(); k != nil; k, raw = c.Prev() {
+			v := new(V)
+			if err := json.Unmarshal(raw, v); err != nil {
+				return err
+			}
+
+			if !fn(string(k), v) {
+				return nil
+			}
+		}
+
+		return nil
+	})
+}
@@ -56,6 +56,10 @@ func (fm *ForgetfulMap[K, V]) Delete(key K) {
 }
 
 func (fm *ForgetfulMap[K, V]) cleanup() {
+	if fm.TTL <= 0 {
+		return
+	}
+
 	fm.Mutex.Lock()
 	for k, inner := range fm.innerMap {
 		if time.Since(inner.accessTime) <= fm.TTL {
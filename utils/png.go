@@ -0,0 +1,149 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+)
+
+var ErrNotPNG = errors.New("not a PNG image")
+
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+type pngChunk struct {
+	Type string
+	Data []byte
+}
+
+func readPNGChunks(png []byte) ([]pngChunk, error) {
+	if !bytes.HasPrefix(png, pngSignature) {
+		return nil, ErrNotPNG
+	}
+
+	chunks := []pngChunk{}
+	for rest := png[len(pngSignature):]; len(rest) >= 12; {
+		length := binary.BigEndian.Uint32(rest[:4])
+		if uint64(len(rest)) < 12+uint64(length) {
+			return nil, ErrNotPNG
+		}
+
+		chunks = append(chunks, pngChunk{Type: string(rest[4:8]), Data: rest[8 : 8+length]})
+		rest = rest[12+length:]
+	}
+
+	return chunks, nil
+}
+
+func writePNGChunk(buf *bytes.Buffer, chunk pngChunk) {
+	_ = binary.Write(buf, binary.BigEndian, uint32(len(chunk.Data)))
+	buf.WriteString(chunk.Type)
+	buf.Write(chunk.Data)
+
+	crc := crc32.NewIEEE()
+	crc.Write([]byte(chunk.Type))
+	crc.Write(chunk.Data)
+	_ = binary.Write(buf, binary.BigEndian, crc.Sum32())
+}
+
+func isLatin1(s string) bool {
+	for _, r := range s {
+		if r > 0xff {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Returns the keyword of a tEXt, iTXt or zTXt chunk
+func textChunkKeyword(chunk pngChunk) string {
+	if chunk.Type != "tEXt" && chunk.Type != "iTXt" && chunk.Type != "zTXt" {
+		return ""
+	}
+
+	keyword, _, _ := bytes.Cut(chunk.Data, []byte{0})
+	return string(keyword)
+}
+
+// Stores text under keyword in a tEXt chunk, or an iTXt chunk if it does not fit in Latin-1, replacing existing text with the same keyword
+func SetPNGText(png []byte, keyword string, text string) ([]byte, error) {
+	chunks, err := readPNGChunks(png)
+	if err != nil {
+		return nil, err
+	}
+
+	var data bytes.Buffer
+	data.WriteString(keyword)
+	data.WriteByte(0)
+
+	chunk := pngChunk{Type: "tEXt"}
+	if isLatin1(text) {
+		for _, r := range text {
+			data.WriteByte(byte(r))
+		}
+	} else {
+		// Uncompressed, without language tag or translated keyword
+		data.Write([]byte{0, 0, 0, 0})
+		data.WriteString(text)
+		chunk.Type = "iTXt"
+	}
+
+	chunk.Data = data.Bytes()
+
+	var buf bytes.Buffer
+	buf.Write(pngSignature)
+	for _, c := range chunks {
+		if textChunkKeyword(c) == keyword {
+			continue
+		}
+
+		// Text goes in front of the image data so readers find it without decoding the image
+		if c.Type == "IDAT" && chunk.Data != nil {
+			writePNGChunk(&buf, chunk)
+			chunk.Data = nil
+		}
+
+		writePNGChunk(&buf, c)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// Reads every tEXt and uncompressed iTXt chunk by keyword
+func GetPNGTexts(png []byte) (map[string]string, error) {
+	chunks, err := readPNGChunks(png)
+	if err != nil {
+		return nil, err
+	}
+
+	texts := map[string]string{}
+	for _, c := range chunks {
+		keyword, rest, found := bytes.Cut(c.Data, []byte{0})
+		if !found {
+			continue
+		}
+
+		switch c.Type {
+		case "tEXt":
+			runes := make([]rune, len(rest))
+			for i, b := range rest {
+				runes[i] = rune(b)
+			}
+
+			texts[string(keyword)] = string(runes)
+		case "iTXt":
+			// Compression flag and method, then the language tag and translated keyword
+			if len(rest) < 2 || rest[0] != 0 {
+				continue
+			}
+
+			fields := bytes.SplitN(rest[2:], []byte{0}, 3)
+			if len(fields) == 3 {
+				texts[string(keyword)] = string(fields[2])
+			}
+		}
+	}
+
+	return texts, nil
+}
@@ -0,0 +1,140 @@
+package utils
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/png"
+	"reflect"
+	"testing"
+)
+
+func testPNG(t *testing.T) []byte {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 4, 4))); err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
+
+// Inserts a chunk right after IHDR
+func withChunk(t *testing.T, raw []byte, chunk pngChunk) []byte {
+	chunks, err := readPNGChunks(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	buf.Write(pngSignature)
+	writePNGChunk(&buf, chunks[0])
+	writePNGChunk(&buf, chunk)
+	for _, c := range chunks[1:] {
+		writePNGChunk(&buf, c)
+	}
+
+	return buf.Bytes()
+}
+
+func TestSetPNGText(t *testing.T) {
+	tests := []struct {
+		name     string
+		texts    [][2]string
+		want     map[string]string
+		wantType string
+	}{
+		{"ascii", [][2]string{{"parameters", "a cat\nSteps: 20"}}, map[string]string{"parameters": "a cat\nSteps: 20"}, "tEXt"},
+		{"latin-1", [][2]string{{"parameters", "café"}}, map[string]string{"parameters": "café"}, "tEXt"},
+		{"utf-8", [][2]string{{"parameters", "猫, 🐱"}}, map[string]string{"parameters": "猫, 🐱"}, "iTXt"},
+		{"replaced", [][2]string{{"parameters", "old"}, {"parameters", "新"}}, map[string]string{"parameters": "新"}, "iTXt"},
+		{"several", [][2]string{{"parameters", "a"}, {"Comment", "b"}}, map[string]string{"parameters": "a", "Comment": "b"}, "tEXt"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			raw := testPNG(t)
+			for _, text := range test.texts {
+				var err error
+				if raw, err = SetPNGText(raw, text[0], text[1]); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			got, err := GetPNGTexts(raw)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("GetPNGTexts() = %q, want %q", got, test.want)
+			}
+
+			chunks, _ := readPNGChunks(raw)
+			texts := 0
+			for i, c := range chunks {
+				if textChunkKeyword(c) == "" {
+					continue
+				}
+
+				texts++
+				if textChunkKeyword(c) == "parameters" && c.Type != test.wantType {
+					t.Errorf("chunk type = %s, want %s", c.Type, test.wantType)
+				}
+				if chunks[i+1].Type != "IDAT" && textChunkKeyword(chunks[i+1]) == "" {
+					t.Errorf("text chunk not in front of the image data")
+				}
+			}
+			if texts != len(test.want) {
+				t.Errorf("%d text chunks, want %d", texts, len(test.want))
+			}
+
+			if _, err := png.Decode(bytes.NewReader(raw)); err != nil {
+				t.Errorf("image no longer decodes: %v", err)
+			}
+		})
+	}
+}
+
+func TestGetPNGTexts(t *testing.T) {
+	tests := []struct {
+		name  string
+		chunk pngChunk
+		want  map[string]string
+	}{
+		{"tEXt", pngChunk{"tEXt", []byte("Comment\x00hello")}, map[string]string{"Comment": "hello"}},
+		{"tEXt latin-1", pngChunk{"tEXt", []byte("Comment\x00caf\xe9")}, map[string]string{"Comment": "café"}},
+		{"iTXt", pngChunk{"iTXt", []byte("parameters\x00\x00\x00\x00\x00猫")}, map[string]string{"parameters": "猫"}},
+		{"iTXt with language", pngChunk{"iTXt", []byte("parameters\x00\x00\x00ja\x00パラメータ\x00猫")}, map[string]string{"parameters": "猫"}},
+		{"compressed iTXt", pngChunk{"iTXt", []byte("parameters\x00\x01\x00\x00\x00x\x9c")}, map[string]string{}},
+		{"zTXt", pngChunk{"zTXt", []byte("parameters\x00\x00x\x9c")}, map[string]string{}},
+		{"no keyword", pngChunk{"tEXt", []byte("hello")}, map[string]string{}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := GetPNGTexts(withChunk(t, testPNG(t), test.chunk))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("GetPNGTexts() = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestNotPNG(t *testing.T) {
+	truncated := testPNG(t)
+	// Cuts into the CRC of the image data
+	truncated = truncated[:len(truncated)-14]
+
+	for name, raw := range map[string][]byte{"jpeg": []byte("\xff\xd8\xff\xe0"), "empty": nil, "truncated": truncated} {
+		t.Run(name, func(t *testing.T) {
+			if _, err := GetPNGTexts(raw); !errors.Is(err, ErrNotPNG) {
+				t.Errorf("GetPNGTexts() error = %v, want %v", err, ErrNotPNG)
+			}
+			if _, err := SetPNGText(raw, "parameters", "a"); !errors.Is(err, ErrNotPNG) {
+				t.Errorf("SetPNGText() error = %v, want %v", err, ErrNotPNG)
+			}
+		})
+	}
+}
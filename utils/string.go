@@ -7,7 +7,10 @@ func TruncateText(s string, max int) string {
 	if max > len(s) {
 		return s
 	}
-	return s[:strings.LastIndexAny(s[:max], " .,:;-")]
+	if i := strings.LastIndexAny(s[:max], " .,:;-"); i >= 0 {
+		return s[:i]
+	}
+	return s[:max]
 }
 
 func StringOrNone(s string) string {